@@ -2,6 +2,7 @@ package ast
 
 import (
 	"maps"
+	"slices"
 
 	"github.com/bogdan-deac/regex/automata"
 	"github.com/bogdan-deac/regex/common/generator"
@@ -19,6 +20,12 @@ const (
 	PlusOp
 	MaybeOp
 	WildcardOp
+	RepeatOp
+	CaptureOp
+	AssertionOp
+	EmptyOp
+	NothingOp
+	CharClassOp
 )
 
 //---------------------------
@@ -29,10 +36,15 @@ type Regex[T automata.StateLike] interface {
 	Opcode() Opcode
 	Optimize() Regex[T]
 	Compile(generator.Generator[T]) *automata.NFA[T]
+	// Pos returns the span of the pattern that the node was parsed from
+	Pos() Span
+	// String returns a pattern for the regex, see Print
+	String() string
 }
 
 type Char[T automata.StateLike] struct {
 	Value rune
+	Span
 }
 
 func (Char[T]) Opcode() Opcode { return CharOp }
@@ -43,20 +55,21 @@ func (c Char[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 		IntialState: intialState,
 		FinalStates: mapset.NewSet(finalState),
 		AllStates:   mapset.NewSet(intialState, finalState),
-		Alphabet:    mapset.NewSet(c.Value),
-		Delta: map[T]map[automata.Symbol][]T{
-			intialState: {
-				c.Value: {finalState},
-			},
+		Delta: map[T][]automata.Transition[T]{
+			intialState: {{Label: automata.SymbolSet(c.Value), Next: finalState}},
 		},
 		EpsilonTransitions: nil,
+		Spans:              spanStates(nil, c.Span, intialState, finalState),
 	}
 }
 
+func (c Char[T]) String() string { return Print[T](c) }
+
 func (c Char[T]) Optimize() Regex[T] { return c }
 
 type Or[T automata.StateLike] struct {
 	Branches []Regex[T]
+	Span
 }
 
 func (Or[T]) Opcode() Opcode { return OrOp }
@@ -64,18 +77,17 @@ func (o Or[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 	intialState := gen.Generate()
 	finalState := gen.Generate()
 	allStates := mapset.NewSet(intialState, finalState)
-	alphabet := mapset.NewSet[automata.Symbol]()
 	epsilonTransitions := make(map[T][]T)
-	delta := make(map[T]map[automata.Symbol][]T)
+	delta := make(map[T][]automata.Transition[T])
+	tags := make(map[T]int)
+	assertions := make(map[T]automata.Assertion)
+	spans := spanStates(nil, o.Span, intialState, finalState)
 	var branchInitialStates []T
 
 	for _, b := range o.Branches {
 		compiledBranch := b.Compile(gen)
 
 		branchInitialStates = append(branchInitialStates, compiledBranch.IntialState)
-		// union of the alphabets of the branches
-		alphabet = alphabet.Union(compiledBranch.Alphabet)
-
 		// join all states together
 		allStates = allStates.Union(compiledBranch.AllStates)
 
@@ -89,6 +101,9 @@ func (o Or[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 
 		// should have no duplicate states, so it's fine to do this
 		maps.Insert(delta, maps.All(compiledBranch.Delta))
+		maps.Insert(tags, maps.All(compiledBranch.Tags))
+		maps.Insert(assertions, maps.All(compiledBranch.Assertions))
+		maps.Insert(spans, maps.All(compiledBranch.Spans))
 	}
 
 	// add an epsilon transition from the initial state to all the final states
@@ -98,29 +113,44 @@ func (o Or[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 		IntialState:        intialState,
 		FinalStates:        mapset.NewSet(finalState),
 		AllStates:          allStates,
-		Alphabet:           alphabet,
 		Delta:              delta,
 		EpsilonTransitions: epsilonTransitions,
+		Tags:               tags,
+		Assertions:         assertions,
+		Spans:              spans,
 	}
 }
 
+func (o Or[T]) String() string { return Print[T](o) }
+
 func (o Or[T]) Optimize() Regex[T] {
 	var newBranches []Regex[T]
 	for _, b := range o.Branches {
 		newBranch := b.Optimize()
+		// a branch which matches nothing never contributes to a match
+		if newBranch.Opcode() == NothingOp {
+			continue
+		}
 		if bo, ok := newBranch.(Or[T]); ok {
 			newBranches = append(newBranches, bo.Branches...)
 			continue
 		}
 		newBranches = append(newBranches, newBranch)
 	}
+	if len(newBranches) == 0 {
+		return Nothing[T]{Span: o.Span}
+	}
 	return Or[T]{
 		Branches: newBranches,
+		Span:     o.Span,
 	}
 }
 
 type Star[T automata.StateLike] struct {
 	Subexp Regex[T]
+	// Lazy quantifiers prefer fewer repetitions when extracting submatches
+	Lazy bool
+	Span
 }
 
 func (Star[T]) Opcode() Opcode { return StarOp }
@@ -135,25 +165,40 @@ func (s Star[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 		epsilonTransitions = make(map[T][]T)
 	}
 
-	epsilonTransitions[intialState] = append(epsilonTransitions[intialState], finalState, subNfa.IntialState)
+	// the order of the epsilon transitions sets the priority of the paths when extracting submatches.
+	// Looping is preferred over leaving, which makes the star greedy, unless it is lazy
+	epsilonTransitions[intialState] = append(epsilonTransitions[intialState], prefer(s.Lazy, subNfa.IntialState, finalState)...)
 	for fs := range subNfa.FinalStates.Iter() {
-		epsilonTransitions[fs] = append(epsilonTransitions[fs], finalState, subNfa.IntialState)
+		epsilonTransitions[fs] = append(epsilonTransitions[fs], prefer(s.Lazy, subNfa.IntialState, finalState)...)
 	}
 
 	return &automata.NFA[T]{
 		IntialState:        intialState,
 		FinalStates:        mapset.NewSet(finalState),
 		AllStates:          allStates.Union(subNfa.AllStates),
-		Alphabet:           subNfa.Alphabet,
 		Delta:              subNfa.Delta,
 		EpsilonTransitions: epsilonTransitions,
+		Tags:               subNfa.Tags,
+		Assertions:         subNfa.Assertions,
+		Spans:              spanStates(maps.Clone(subNfa.Spans), s.Span, intialState, finalState),
 	}
 }
 
-func (s Star[T]) Optimize() Regex[T] { return s }
+func (s Star[T]) String() string { return Print[T](s) }
+
+func (s Star[T]) Optimize() Regex[T] {
+	subexp := s.Subexp.Optimize()
+	if op := subexp.Opcode(); op == EmptyOp || op == NothingOp {
+		return Empty[T]{Span: s.Span}
+	}
+	return Star[T]{Subexp: subexp, Lazy: s.Lazy, Span: s.Span}
+}
 
 type Plus[T automata.StateLike] struct {
 	Subexp Regex[T]
+	// Lazy quantifiers prefer fewer repetitions when extracting submatches
+	Lazy bool
+	Span
 }
 
 func (Plus[T]) Opcode() Opcode { return PlusOp }
@@ -171,24 +216,35 @@ func (p Plus[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 
 	epsilonTransitions[intialState] = append(epsilonTransitions[intialState], subNfa.IntialState)
 	for fs := range subNfa.FinalStates.Iter() {
-		epsilonTransitions[fs] = append(epsilonTransitions[fs], finalState, subNfa.IntialState)
+		epsilonTransitions[fs] = append(epsilonTransitions[fs], prefer(p.Lazy, subNfa.IntialState, finalState)...)
 	}
 
 	return &automata.NFA[T]{
 		IntialState:        intialState,
 		FinalStates:        mapset.NewSet(finalState),
 		AllStates:          allStates.Union(subNfa.AllStates),
-		Alphabet:           subNfa.Alphabet,
 		Delta:              subNfa.Delta,
 		EpsilonTransitions: epsilonTransitions,
+		Tags:               subNfa.Tags,
+		Assertions:         subNfa.Assertions,
+		Spans:              spanStates(maps.Clone(subNfa.Spans), p.Span, intialState, finalState),
 	}
 }
 
-func (p Plus[T]) Optimize() Regex[T] { return p }
+func (p Plus[T]) String() string { return Print[T](p) }
+
+func (p Plus[T]) Optimize() Regex[T] {
+	subexp := p.Subexp.Optimize()
+	if op := subexp.Opcode(); op == EmptyOp || op == NothingOp {
+		return subexp
+	}
+	return Plus[T]{Subexp: subexp, Lazy: p.Lazy, Span: p.Span}
+}
 
 type Cat[T automata.StateLike] struct {
 	Left  Regex[T]
 	Right Regex[T]
+	Span
 }
 
 func (Cat[T]) Opcode() Opcode { return CatOp }
@@ -196,11 +252,28 @@ func (c Cat[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 	lc := c.Left.Compile(gen)
 	rc := c.Right.Compile(gen)
 	allStates := mapset.NewSet[T]().Union(lc.AllStates).Union(rc.AllStates)
-	alphabet := mapset.NewSet[automata.Symbol]().Union(lc.Alphabet).Union(rc.Alphabet)
 
 	delta := maps.Clone(lc.Delta)
+	if delta == nil {
+		delta = make(map[T][]automata.Transition[T])
+	}
 	maps.Insert(delta, maps.All(rc.Delta))
 
+	tags := maps.Clone(lc.Tags)
+	if tags == nil {
+		tags = make(map[T]int)
+	}
+	maps.Insert(tags, maps.All(rc.Tags))
+
+	assertions := maps.Clone(lc.Assertions)
+	if assertions == nil {
+		assertions = make(map[T]automata.Assertion)
+	}
+	maps.Insert(assertions, maps.All(rc.Assertions))
+
+	spans := spanStates(maps.Clone(lc.Spans), c.Span)
+	maps.Insert(spans, maps.All(rc.Spans))
+
 	epsilonTransitions := maps.Clone(lc.EpsilonTransitions)
 	if epsilonTransitions == nil {
 		epsilonTransitions = make(map[T][]T)
@@ -216,21 +289,39 @@ func (c Cat[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 		IntialState:        lc.IntialState,
 		FinalStates:        rc.FinalStates,
 		AllStates:          allStates,
-		Alphabet:           alphabet,
 		Delta:              delta,
 		EpsilonTransitions: epsilonTransitions,
+		Tags:               tags,
+		Assertions:         assertions,
+		Spans:              spans,
 	}
 }
 
+func (c Cat[T]) String() string { return Print[T](c) }
+
 func (c Cat[T]) Optimize() Regex[T] {
+	left := c.Left.Optimize()
+	right := c.Right.Optimize()
+	switch {
+	case left.Opcode() == NothingOp || right.Opcode() == NothingOp:
+		return Nothing[T]{Span: c.Span}
+	case left.Opcode() == EmptyOp:
+		return right
+	case right.Opcode() == EmptyOp:
+		return left
+	}
 	return Cat[T]{
-		Left:  c.Left.Optimize(),
-		Right: c.Right.Optimize(),
+		Left:  left,
+		Right: right,
+		Span:  c.Span,
 	}
 }
 
 type Maybe[T automata.StateLike] struct {
 	Subexp Regex[T]
+	// Lazy quantifiers prefer fewer repetitions when extracting submatches
+	Lazy bool
+	Span
 }
 
 func (Maybe[T]) Opcode() Opcode { return MaybeOp }
@@ -245,7 +336,7 @@ func (m Maybe[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 		epsilonTransitions = make(map[T][]T)
 	}
 
-	epsilonTransitions[intialState] = append(epsilonTransitions[intialState], finalState, subNfa.IntialState)
+	epsilonTransitions[intialState] = append(epsilonTransitions[intialState], prefer(m.Lazy, subNfa.IntialState, finalState)...)
 	for fs := range subNfa.FinalStates.Iter() {
 		epsilonTransitions[fs] = append(epsilonTransitions[fs], finalState)
 	}
@@ -254,15 +345,27 @@ func (m Maybe[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 		IntialState:        intialState,
 		FinalStates:        mapset.NewSet(finalState),
 		AllStates:          allStates.Union(subNfa.AllStates),
-		Alphabet:           subNfa.Alphabet,
 		Delta:              subNfa.Delta,
 		EpsilonTransitions: epsilonTransitions,
+		Tags:               subNfa.Tags,
+		Assertions:         subNfa.Assertions,
+		Spans:              spanStates(maps.Clone(subNfa.Spans), m.Span, intialState, finalState),
 	}
 }
 
-func (m Maybe[T]) Optimize() Regex[T] { return m }
+func (m Maybe[T]) String() string { return Print[T](m) }
 
-type Wildcard[T automata.StateLike] struct{}
+func (m Maybe[T]) Optimize() Regex[T] {
+	subexp := m.Subexp.Optimize()
+	if op := subexp.Opcode(); op == EmptyOp || op == NothingOp {
+		return Empty[T]{Span: m.Span}
+	}
+	return Maybe[T]{Subexp: subexp, Lazy: m.Lazy, Span: m.Span}
+}
+
+type Wildcard[T automata.StateLike] struct {
+	Span
+}
 
 func (w Wildcard[T]) Opcode() Opcode { return WildcardOp }
 
@@ -273,13 +376,395 @@ func (w Wildcard[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
 		IntialState: initialState,
 		FinalStates: mapset.NewSet(finalState),
 		AllStates:   mapset.NewSet(initialState, finalState),
-		Delta: map[T]map[automata.Symbol][]T{
-			initialState: {
-				automata.Wildcard: {finalState},
-			},
+		Delta: map[T][]automata.Transition[T]{
+			initialState: {{Label: automata.Unicode, Next: finalState}},
 		},
-		Alphabet: mapset.NewSet[automata.Symbol](automata.Wildcard),
+		Spans: spanStates(nil, w.Span, initialState, finalState),
 	}
 }
 
+func (w Wildcard[T]) String() string { return Print[T](w) }
+
 func (w Wildcard[T]) Optimize() Regex[T] { return w }
+
+// CharClass matches any single character of a class. It compiles to a single transition labelled
+// with the class, however many characters it holds
+type CharClass[T automata.StateLike] struct {
+	Class
+	Span
+}
+
+func (CharClass[T]) Opcode() Opcode { return CharClassOp }
+
+func (c CharClass[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
+	initialState := gen.Generate()
+	finalState := gen.Generate()
+	return &automata.NFA[T]{
+		IntialState: initialState,
+		FinalStates: mapset.NewSet(finalState),
+		AllStates:   mapset.NewSet(initialState, finalState),
+		Delta: map[T][]automata.Transition[T]{
+			initialState: {{Label: automata.IntervalSet(c.Ranges), Next: finalState}},
+		},
+		Spans: spanStates(nil, c.Span, initialState, finalState),
+	}
+}
+
+func (c CharClass[T]) String() string { return Print[T](c) }
+
+func (c CharClass[T]) Optimize() Regex[T] {
+	if c.IsEmpty() {
+		return Nothing[T]{Span: c.Span}
+	}
+	return c
+}
+
+// Unbounded marks a Repeat without an upper bound
+const Unbounded = -1
+
+// Repeat matches between Min and Max occurrences of the subexpression. Max is Unbounded for the
+// {n,} quantifier
+type Repeat[T automata.StateLike] struct {
+	Subexp Regex[T]
+	Min    int
+	Max    int
+	Lazy   bool
+	Span
+}
+
+func (Repeat[T]) Opcode() Opcode { return RepeatOp }
+
+// Compile chains fresh copies of the subexpression, so the NFA grows linearly with the counts.
+// The optional copies are nested - each of them can skip straight to the final state - which keeps
+// the epsilon closures small: x{0,3} is built as (x(x(x)?)?)? rather than x?x?x?
+func (r Repeat[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
+	intialState := gen.Generate()
+	finalState := gen.Generate()
+	nfa := &automata.NFA[T]{
+		IntialState:        intialState,
+		FinalStates:        mapset.NewSet(finalState),
+		AllStates:          mapset.NewSet(intialState, finalState),
+		Delta:              make(map[T][]automata.Transition[T]),
+		EpsilonTransitions: make(map[T][]T),
+		Tags:               make(map[T]int),
+		Assertions:         make(map[T]automata.Assertion),
+		Spans:              spanStates(nil, r.Span, intialState, finalState),
+	}
+
+	// the state after the copies appended so far
+	tail := intialState
+	appendCopy := func() *automata.NFA[T] {
+		subNfa := r.Subexp.Compile(gen)
+		absorb(nfa, subNfa)
+		nfa.EpsilonTransitions[tail] = append(nfa.EpsilonTransitions[tail], subNfa.IntialState)
+		tail = gen.Generate()
+		nfa.AllStates.Add(tail)
+		nfa.Spans[tail] = automata.Span(r.Span)
+		for fs := range subNfa.FinalStates.Iter() {
+			nfa.EpsilonTransitions[fs] = append(nfa.EpsilonTransitions[fs], tail)
+		}
+		return subNfa
+	}
+
+	for range r.Min {
+		appendCopy()
+	}
+
+	// as for the other quantifiers, another copy is preferred over skipping to the end, unless the
+	// repetition is lazy
+	switch {
+	case r.Max == Unbounded:
+		// loop on one more copy
+		loopState := tail
+		subNfa := appendCopy()
+		for fs := range subNfa.FinalStates.Iter() {
+			nfa.EpsilonTransitions[fs] = prefer(r.Lazy, subNfa.IntialState, nfa.EpsilonTransitions[fs]...)
+		}
+		nfa.EpsilonTransitions[loopState] = prefer(r.Lazy, subNfa.IntialState, finalState)
+	default:
+		for range r.Max - r.Min {
+			skipState := tail
+			subNfa := appendCopy()
+			nfa.EpsilonTransitions[skipState] = prefer(r.Lazy, subNfa.IntialState, finalState)
+		}
+	}
+	nfa.EpsilonTransitions[tail] = append(nfa.EpsilonTransitions[tail], finalState)
+
+	return nfa
+}
+
+func (r Repeat[T]) String() string { return Print[T](r) }
+
+func (r Repeat[T]) Optimize() Regex[T] {
+	subexp := r.Subexp.Optimize()
+	switch {
+	case subexp.Opcode() == EmptyOp:
+		return subexp
+	case subexp.Opcode() == NothingOp:
+		if r.Min == 0 {
+			return Empty[T]{Span: r.Span}
+		}
+		return subexp
+	case r.Min == 0 && r.Max == Unbounded:
+		return Star[T]{Subexp: subexp, Lazy: r.Lazy, Span: r.Span}
+	case r.Min == 1 && r.Max == Unbounded:
+		return Plus[T]{Subexp: subexp, Lazy: r.Lazy, Span: r.Span}
+	case r.Min == 0 && r.Max == 1:
+		return Maybe[T]{Subexp: subexp, Lazy: r.Lazy, Span: r.Span}
+	case r.Min == 1 && r.Max == 1:
+		return subexp
+	}
+	return Repeat[T]{
+		Subexp: subexp,
+		Min:    r.Min,
+		Max:    r.Max,
+		Lazy:   r.Lazy,
+		Span:   r.Span,
+	}
+}
+
+// prefer orders the epsilon transitions out of a quantifier: repeating the subexpression once more
+// comes first, unless the quantifier is lazy
+func prefer[T automata.StateLike](lazy bool, again T, done ...T) []T {
+	if lazy {
+		return append(slices.Clip(done), again)
+	}
+	return append([]T{again}, done...)
+}
+
+// absorb copies all the states and transitions of src into dst. The states of the two automata
+// must be disjoint
+func absorb[T automata.StateLike](dst, src *automata.NFA[T]) {
+	dst.AllStates.Append(src.AllStates.ToSlice()...)
+	maps.Insert(dst.Delta, maps.All(src.Delta))
+	maps.Insert(dst.EpsilonTransitions, maps.All(src.EpsilonTransitions))
+	maps.Insert(dst.Tags, maps.All(src.Tags))
+	maps.Insert(dst.Assertions, maps.All(src.Assertions))
+	maps.Insert(dst.Spans, maps.All(src.Spans))
+}
+
+// Capture is a capturing group. Groups are numbered from 1, in the order of their opening
+// parentheses, and may also be named
+type Capture[T automata.StateLike] struct {
+	Subexp Regex[T]
+	Index  int
+	Name   string
+	Span
+}
+
+func (Capture[T]) Opcode() Opcode { return CaptureOp }
+
+// Compile surrounds the subexpression with a pair of tagged states, which record where the group
+// starts and ends when the NFA is simulated
+func (c Capture[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
+	openState := gen.Generate()
+	closeState := gen.Generate()
+	allStates := mapset.NewSet(openState, closeState)
+
+	subNfa := c.Subexp.Compile(gen)
+	epsilonTransitions := maps.Clone(subNfa.EpsilonTransitions)
+	if epsilonTransitions == nil {
+		epsilonTransitions = make(map[T][]T)
+	}
+	epsilonTransitions[openState] = []T{subNfa.IntialState}
+	for fs := range subNfa.FinalStates.Iter() {
+		epsilonTransitions[fs] = append(epsilonTransitions[fs], closeState)
+	}
+
+	tags := maps.Clone(subNfa.Tags)
+	if tags == nil {
+		tags = make(map[T]int)
+	}
+	tags[openState] = 2 * c.Index
+	tags[closeState] = 2*c.Index + 1
+
+	return &automata.NFA[T]{
+		IntialState:        openState,
+		FinalStates:        mapset.NewSet(closeState),
+		AllStates:          allStates.Union(subNfa.AllStates),
+		Delta:              subNfa.Delta,
+		EpsilonTransitions: epsilonTransitions,
+		Tags:               tags,
+		Assertions:         subNfa.Assertions,
+		Spans:              spanStates(maps.Clone(subNfa.Spans), c.Span, openState, closeState),
+	}
+}
+
+func (c Capture[T]) String() string { return Print[T](c) }
+
+func (c Capture[T]) Optimize() Regex[T] {
+	return Capture[T]{
+		Subexp: c.Subexp.Optimize(),
+		Index:  c.Index,
+		Name:   c.Name,
+		Span:   c.Span,
+	}
+}
+
+// Assertion matches the empty string, at the positions of the input where all of its assertions
+// hold. It stands for the ^, $, \A and \z anchors and for the \b and \B word boundaries
+type Assertion[T automata.StateLike] struct {
+	Kind automata.Assertion
+	Span
+}
+
+func (Assertion[T]) Opcode() Opcode { return AssertionOp }
+
+// Compile links two states with an epsilon transition, which the assertions guard
+func (a Assertion[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
+	initialState := gen.Generate()
+	finalState := gen.Generate()
+	return &automata.NFA[T]{
+		IntialState: initialState,
+		FinalStates: mapset.NewSet(finalState),
+		AllStates:   mapset.NewSet(initialState, finalState),
+		Delta:       map[T][]automata.Transition[T]{},
+		EpsilonTransitions: map[T][]T{
+			initialState: {finalState},
+		},
+		Assertions: map[T]automata.Assertion{
+			initialState: a.Kind,
+		},
+		Spans: spanStates(nil, a.Span, initialState, finalState),
+	}
+}
+
+func (a Assertion[T]) String() string { return Print[T](a) }
+
+func (a Assertion[T]) Optimize() Regex[T] { return a }
+
+// Empty matches the empty string. It stands for empty alternatives and groups, as in a| and ()
+type Empty[T automata.StateLike] struct {
+	Span
+}
+
+func (Empty[T]) Opcode() Opcode { return EmptyOp }
+
+func (e Empty[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
+	initialState := gen.Generate()
+	finalState := gen.Generate()
+	return &automata.NFA[T]{
+		IntialState: initialState,
+		FinalStates: mapset.NewSet(finalState),
+		AllStates:   mapset.NewSet(initialState, finalState),
+		Delta:       map[T][]automata.Transition[T]{},
+		EpsilonTransitions: map[T][]T{
+			initialState: {finalState},
+		},
+		Spans: spanStates(nil, e.Span, initialState, finalState),
+	}
+}
+
+func (e Empty[T]) String() string { return Print[T](e) }
+
+func (e Empty[T]) Optimize() Regex[T] { return e }
+
+// Nothing matches no string at all, not even the empty one. It stands for sets without any
+// character
+type Nothing[T automata.StateLike] struct {
+	Span
+}
+
+func (Nothing[T]) Opcode() Opcode { return NothingOp }
+
+// Compile returns an automaton without any final state
+func (n Nothing[T]) Compile(gen generator.Generator[T]) *automata.NFA[T] {
+	initialState := gen.Generate()
+	return &automata.NFA[T]{
+		IntialState: initialState,
+		FinalStates: mapset.NewSet[T](),
+		AllStates:   mapset.NewSet(initialState),
+		Delta:       map[T][]automata.Transition[T]{},
+		Spans:       spanStates(nil, n.Span, initialState),
+	}
+}
+
+func (n Nothing[T]) String() string { return Print[T](n) }
+
+func (n Nothing[T]) Optimize() Regex[T] { return n }
+
+// CaptureNames returns the names of the capture groups of a regex, indexed by group number. Group 0
+// stands for the whole match and unnamed groups have empty names
+func CaptureNames[T automata.StateLike](re Regex[T]) []string {
+	names := []string{""}
+	Walk(re, func(re Regex[T]) {
+		if c, ok := re.(Capture[T]); ok {
+			for len(names) <= c.Index {
+				names = append(names, "")
+			}
+			names[c.Index] = c.Name
+		}
+	})
+	return names
+}
+
+// Walk calls visit on a regex and then on each of its subexpressions, in the order they appear in
+// the pattern
+func Walk[T automata.StateLike](re Regex[T], visit func(Regex[T])) {
+	visit(re)
+	for _, subexp := range subexpressions(re) {
+		Walk(subexp, visit)
+	}
+}
+
+// NumberCaptures returns a copy of a regex with its capture groups numbered from 1, in the order
+// of their opening parentheses, as the parser numbers them
+func NumberCaptures[T automata.StateLike](re Regex[T]) Regex[T] {
+	next := 1
+	var number func(Regex[T]) Regex[T]
+	number = func(re Regex[T]) Regex[T] {
+		if c, ok := re.(Capture[T]); ok {
+			c.Index = next
+			next++
+			re = c
+		}
+		subexps := slices.Clone(subexpressions(re))
+		for i, subexp := range subexps {
+			subexps[i] = number(subexp)
+		}
+		return withSubexpressions(re, subexps)
+	}
+	return number(re)
+}
+
+// RepeatSize returns the largest product of the counts of nested Repeat nodes in a regex, which
+// bounds how many copies of a subexpression the regex compiles to. The upper count of a Repeat is
+// used, or its lower count if it is Unbounded. Products larger than limit are reported as limit+1
+func RepeatSize[T automata.StateLike](re Regex[T], limit int) int {
+	size := 1
+	for _, subexp := range subexpressions(re) {
+		size = max(size, RepeatSize(subexp, limit))
+	}
+	if r, ok := re.(Repeat[T]); ok {
+		count := r.Max
+		if count == Unbounded {
+			count = r.Min
+		}
+		if count > 0 && size > limit/count {
+			return limit + 1
+		}
+		size *= count
+	}
+	return min(size, limit+1)
+}
+
+// subexpressions returns the direct subexpressions of a regex
+func subexpressions[T automata.StateLike](re Regex[T]) []Regex[T] {
+	switch r := re.(type) {
+	case Or[T]:
+		return r.Branches
+	case Cat[T]:
+		return []Regex[T]{r.Left, r.Right}
+	case Star[T]:
+		return []Regex[T]{r.Subexp}
+	case Plus[T]:
+		return []Regex[T]{r.Subexp}
+	case Maybe[T]:
+		return []Regex[T]{r.Subexp}
+	case Repeat[T]:
+		return []Regex[T]{r.Subexp}
+	case Capture[T]:
+		return []Regex[T]{r.Subexp}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package ast
+
+import (
+	"slices"
+	"unicode"
+
+	"github.com/bogdan-deac/regex/automata"
+)
+
+// CharRange is an inclusive range of characters
+type CharRange = automata.Interval
+
+// Class is a set of characters, kept as sorted ranges which neither overlap nor touch, so that
+// two classes with the same characters are equal
+type Class struct {
+	Ranges []CharRange
+}
+
+// NewClass builds the class of the characters in the given ranges
+func NewClass(ranges ...CharRange) Class {
+	return Class{Ranges: automata.NewIntervalSet(ranges...)}
+}
+
+// IsEmpty reports whether the class holds no character
+func (c Class) IsEmpty() bool {
+	return len(c.Ranges) == 0
+}
+
+// Len returns the number of characters in the class
+func (c Class) Len() int {
+	return automata.IntervalSet(c.Ranges).Len()
+}
+
+// Contains reports whether a character belongs to the class
+func (c Class) Contains(r rune) bool {
+	return automata.IntervalSet(c.Ranges).Contains(r)
+}
+
+// Negate returns the class of all the characters outside of c, up to unicode.MaxRune
+func (c Class) Negate() Class {
+	return Class{Ranges: automata.Unicode.Difference(c.Ranges)}
+}
+
+// Union returns the class of the characters in either c or other
+func (c Class) Union(other Class) Class {
+	return Class{Ranges: automata.IntervalSet(c.Ranges).Union(other.Ranges)}
+}
+
+// Intersect returns the class of the characters in both c and other
+func (c Class) Intersect(other Class) Class {
+	return Class{Ranges: automata.IntervalSet(c.Ranges).Intersect(other.Ranges)}
+}
+
+// Fold adds the other cases of each character to the class, following the simple case folding of
+// Unicode. A class negated afterwards excludes all the cases of its characters
+func (c Class) Fold() Class {
+	ranges := slices.Clone(c.Ranges)
+	for _, r := range c.Ranges {
+		// no character above the last case range has other cases
+		for char := r.Lo; char <= min(r.Hi, maxCased); char++ {
+			for folded := unicode.SimpleFold(char); folded != char; folded = unicode.SimpleFold(folded) {
+				ranges = append(ranges, CharRange{Lo: folded, Hi: folded})
+			}
+		}
+	}
+	return NewClass(ranges...)
+}
+
+var maxCased = rune(unicode.CaseRanges[len(unicode.CaseRanges)-1].Hi)
+
+// ClassRegex builds a regex matching a single character of a class
+func ClassRegex[T automata.StateLike](c Class) Regex[T] {
+	if c.IsEmpty() {
+		return Nothing[T]{}
+	}
+	return CharClass[T]{Class: c}
+}
+
+// PerlClass returns the class of a \d, \s or \w shorthand, given its letter. The uppercase letters
+// \D, \S and \W stand for the negated classes
+func PerlClass(letter rune) (Class, bool) {
+	switch letter {
+	case 'd', 's', 'w':
+		return NewClass(perlClasses[letter]...), true
+	case 'D', 'S', 'W':
+		return NewClass(perlClasses[letter-'A'+'a']...).Negate(), true
+	}
+	return Class{}, false
+}
+
+// PosixClass returns the class of a [:name:] bracket expression, given its name
+func PosixClass(name string) (Class, bool) {
+	ranges, ok := posixClasses[name]
+	if !ok {
+		return Class{}, false
+	}
+	return NewClass(ranges...), true
+}
+
+var perlClasses = map[rune][]CharRange{
+	'd': {{Lo: '0', Hi: '9'}},
+	's': {{Lo: '\t', Hi: '\n'}, {Lo: '\f', Hi: '\r'}, {Lo: ' ', Hi: ' '}},
+	'w': {{Lo: '0', Hi: '9'}, {Lo: 'A', Hi: 'Z'}, {Lo: '_', Hi: '_'}, {Lo: 'a', Hi: 'z'}},
+}
+
+var posixClasses = map[string][]CharRange{
+	"alnum":  {{Lo: '0', Hi: '9'}, {Lo: 'A', Hi: 'Z'}, {Lo: 'a', Hi: 'z'}},
+	"alpha":  {{Lo: 'A', Hi: 'Z'}, {Lo: 'a', Hi: 'z'}},
+	"ascii":  {{Lo: 0x00, Hi: 0x7F}},
+	"blank":  {{Lo: '\t', Hi: '\t'}, {Lo: ' ', Hi: ' '}},
+	"cntrl":  {{Lo: 0x00, Hi: 0x1F}, {Lo: 0x7F, Hi: 0x7F}},
+	"digit":  {{Lo: '0', Hi: '9'}},
+	"graph":  {{Lo: '!', Hi: '~'}},
+	"lower":  {{Lo: 'a', Hi: 'z'}},
+	"print":  {{Lo: ' ', Hi: '~'}},
+	"punct":  {{Lo: '!', Hi: '/'}, {Lo: ':', Hi: '@'}, {Lo: '[', Hi: '`'}, {Lo: '{', Hi: '~'}},
+	"space":  {{Lo: '\t', Hi: '\r'}, {Lo: ' ', Hi: ' '}},
+	"upper":  {{Lo: 'A', Hi: 'Z'}},
+	"word":   {{Lo: '0', Hi: '9'}, {Lo: 'A', Hi: 'Z'}, {Lo: '_', Hi: '_'}, {Lo: 'a', Hi: 'z'}},
+	"xdigit": {{Lo: '0', Hi: '9'}, {Lo: 'A', Hi: 'F'}, {Lo: 'a', Hi: 'f'}},
+}
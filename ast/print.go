@@ -0,0 +1,187 @@
+package ast
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bogdan-deac/regex/automata"
+)
+
+// the precedence of the operators, from the loosest to the tightest binding
+const (
+	precAlt = iota
+	precConcat
+	precRepeat
+	precAtom
+)
+
+// Print returns a pattern for a regex, with as few parentheses as possible. Parsing the pattern
+// without flags gives back the regex, as long as it has the shape built by the parser: Or and Cat
+// nodes nested to the left, and sets of characters as CharClass nodes
+func Print[T automata.StateLike](re Regex[T]) string {
+	var sb strings.Builder
+	printRegex(&sb, re, precAlt)
+	return sb.String()
+}
+
+// printRegex writes a regex, in a non-capturing group if it binds looser than prec
+func printRegex[T automata.StateLike](sb *strings.Builder, re Regex[T], prec int) {
+	if precedence(re) < prec {
+		sb.WriteString("(?:")
+		defer sb.WriteString(")")
+	}
+
+	switch r := re.(type) {
+	case Char[T]:
+		sb.WriteString(escape(r.Value, "\\.+*?()|[]{}^$"))
+	case Or[T]:
+		for i, b := range r.Branches {
+			if i > 0 {
+				sb.WriteByte('|')
+			}
+			// alternations nest to the left, so the alternations after the first branch are grouped
+			if i > 0 && b.Opcode() == OrOp {
+				printRegex(sb, b, precConcat)
+				continue
+			}
+			printRegex(sb, b, precAlt)
+		}
+	case Cat[T]:
+		// as for alternations, concatenations nest to the left
+		printRegex(sb, r.Left, precConcat)
+		printRegex(sb, r.Right, precRepeat)
+	case Star[T]:
+		printRegex(sb, r.Subexp, precAtom)
+		sb.WriteString(lazy("*", r.Lazy))
+	case Plus[T]:
+		printRegex(sb, r.Subexp, precAtom)
+		sb.WriteString(lazy("+", r.Lazy))
+	case Maybe[T]:
+		printRegex(sb, r.Subexp, precAtom)
+		sb.WriteString(lazy("?", r.Lazy))
+	case Repeat[T]:
+		printRegex(sb, r.Subexp, precAtom)
+		bounds := "{" + strconv.Itoa(r.Min)
+		switch r.Max {
+		case r.Min:
+		case Unbounded:
+			bounds += ","
+		default:
+			bounds += "," + strconv.Itoa(r.Max)
+		}
+		sb.WriteString(lazy(bounds+"}", r.Lazy))
+	case Wildcard[T]:
+		sb.WriteString("(?s:.)")
+	case CharClass[T]:
+		excluded := r.Negate()
+		switch {
+		case slices.Equal(excluded.Ranges, []CharRange{{Lo: '\n', Hi: '\n'}}):
+			sb.WriteByte('.')
+		case excluded.IsEmpty():
+			sb.WriteString(`[\d\D]`)
+		case r.Contains(unicode.MaxRune):
+			// a class reaching the last character is most likely a negated one
+			printSet(sb, excluded.Ranges, true)
+		default:
+			printSet(sb, r.Ranges, false)
+		}
+	case Capture[T]:
+		sb.WriteByte('(')
+		if r.Name != "" {
+			sb.WriteString("?P<" + r.Name + ">")
+		}
+		printRegex(sb, r.Subexp, precAlt)
+		sb.WriteByte(')')
+	case Assertion[T]:
+		printAssertion(sb, r.Kind)
+	case Empty[T]:
+	case Nothing[T]:
+		sb.WriteString(`[^\d\D]`)
+	}
+}
+
+// precedence returns how tightly the outermost operator of a regex binds
+func precedence[T automata.StateLike](re Regex[T]) int {
+	switch re.(type) {
+	case Or[T]:
+		return precAlt
+	case Empty[T]:
+		// the empty string can only stand on its own as an alternative
+		return precAlt
+	case Cat[T]:
+		return precConcat
+	case Star[T], Plus[T], Maybe[T], Repeat[T]:
+		return precRepeat
+	}
+	return precAtom
+}
+
+// printSet writes a set of sorted ranges. Ranges of one or two characters are written as plain
+// characters
+func printSet(sb *strings.Builder, ranges []CharRange, negated bool) {
+	sb.WriteByte('[')
+	if negated {
+		sb.WriteByte('^')
+	}
+	for _, r := range ranges {
+		sb.WriteString(escape(r.Lo, `\[]^-`))
+		switch {
+		case r.Hi-r.Lo >= 2:
+			sb.WriteString("-" + escape(r.Hi, `\[]^-`))
+		case r.Hi > r.Lo:
+			sb.WriteString(escape(r.Hi, `\[]^-`))
+		}
+	}
+	sb.WriteByte(']')
+}
+
+func printAssertion(sb *strings.Builder, kind automata.Assertion) {
+	for _, a := range []struct {
+		kind    automata.Assertion
+		pattern string
+	}{
+		{automata.BeginLine, "(?m:^)"},
+		{automata.BeginText, "^"},
+		{automata.WordBoundary, `\b`},
+		{automata.NoWordBoundary, `\B`},
+		{automata.EndLine, "(?m:$)"},
+		{automata.EndText, "$"},
+	} {
+		if kind&a.kind != 0 {
+			sb.WriteString(a.pattern)
+		}
+	}
+}
+
+// escape returns the pattern of a single character. The special characters are escaped, and the
+// characters which cannot be printed are given by their code points
+func escape(c rune, special string) string {
+	switch {
+	case strings.ContainsRune(special, c):
+		return `\` + string(c)
+	case c == '\n':
+		return `\n`
+	case c == '\t':
+		return `\t`
+	case c == '\r':
+		return `\r`
+	case c == '\f':
+		return `\f`
+	case c == '\v':
+		return `\v`
+	case c == '\a':
+		return `\a`
+	case !unicode.IsPrint(c):
+		return `\x{` + strconv.FormatInt(int64(c), 16) + "}"
+	}
+	return string(c)
+}
+
+func lazy(quantifier string, lazy bool) string {
+	if lazy {
+		return quantifier + "?"
+	}
+	return quantifier
+}
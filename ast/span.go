@@ -0,0 +1,114 @@
+package ast
+
+import (
+	"slices"
+
+	"github.com/bogdan-deac/regex/automata"
+)
+
+// Span is the range of byte offsets of the pattern that a node was parsed from, from Start up to
+// End excluded. Nodes built by hand have an empty span
+type Span struct {
+	Start int
+	End   int
+}
+
+// Pos returns the span of a node
+func (s Span) Pos() Span { return s }
+
+// WithSpan returns a copy of a node with its span set
+func WithSpan[T automata.StateLike](re Regex[T], span Span) Regex[T] {
+	switch r := re.(type) {
+	case Char[T]:
+		r.Span = span
+		return r
+	case Or[T]:
+		r.Span = span
+		return r
+	case Cat[T]:
+		r.Span = span
+		return r
+	case Star[T]:
+		r.Span = span
+		return r
+	case Plus[T]:
+		r.Span = span
+		return r
+	case Maybe[T]:
+		r.Span = span
+		return r
+	case Wildcard[T]:
+		r.Span = span
+		return r
+	case CharClass[T]:
+		r.Span = span
+		return r
+	case Repeat[T]:
+		r.Span = span
+		return r
+	case Capture[T]:
+		r.Span = span
+		return r
+	case Assertion[T]:
+		r.Span = span
+		return r
+	case Empty[T]:
+		r.Span = span
+		return r
+	case Nothing[T]:
+		r.Span = span
+		return r
+	}
+	return re
+}
+
+// StripSpans returns a copy of a regex with the spans of all its nodes cleared, so that regexes
+// parsed from different patterns can be compared structurally
+func StripSpans[T automata.StateLike](re Regex[T]) Regex[T] {
+	subexps := slices.Clone(subexpressions(re))
+	for i, subexp := range subexps {
+		subexps[i] = StripSpans(subexp)
+	}
+	return WithSpan(withSubexpressions(re, subexps), Span{})
+}
+
+// withSubexpressions returns a copy of a regex with its direct subexpressions replaced, in the
+// order returned by subexpressions
+func withSubexpressions[T automata.StateLike](re Regex[T], subexps []Regex[T]) Regex[T] {
+	switch r := re.(type) {
+	case Or[T]:
+		r.Branches = subexps
+		return r
+	case Cat[T]:
+		r.Left, r.Right = subexps[0], subexps[1]
+		return r
+	case Star[T]:
+		r.Subexp = subexps[0]
+		return r
+	case Plus[T]:
+		r.Subexp = subexps[0]
+		return r
+	case Maybe[T]:
+		r.Subexp = subexps[0]
+		return r
+	case Repeat[T]:
+		r.Subexp = subexps[0]
+		return r
+	case Capture[T]:
+		r.Subexp = subexps[0]
+		return r
+	}
+	return re
+}
+
+// spanStates maps the states created by a node to its span, in the spans of the states of an
+// automaton
+func spanStates[T automata.StateLike](spans map[T]automata.Span, span Span, states ...T) map[T]automata.Span {
+	if spans == nil {
+		spans = make(map[T]automata.Span, len(states))
+	}
+	for _, state := range states {
+		spans[state] = automata.Span(span)
+	}
+	return spans
+}
@@ -0,0 +1,169 @@
+package automata
+
+import (
+	"cmp"
+	"iter"
+	"math/big"
+	"slices"
+
+	set "github.com/deckarep/golang-set/v2"
+)
+
+// IsEmpty reports whether the DFA accepts no string at all. As in Count and Strings, only the
+// symbols that a string can hold are followed
+func (dfa *DFA[T]) IsEmpty() bool {
+	return dfa.reachableStates(runes).Intersect(dfa.FinalStates).IsEmpty()
+}
+
+// IsUniversal reports whether the DFA accepts every input made of symbols of an alphabet: every
+// state reachable on symbols of the alphabet is final and has transitions on the whole alphabet
+func (dfa *DFA[T]) IsUniversal(alphabet IntervalSet) bool {
+	for state := range dfa.reachableStates(alphabet).Iter() {
+		if !dfa.FinalStates.Contains(state) {
+			return false
+		}
+		missing := alphabet
+		for _, t := range dfa.Delta[state] {
+			missing = missing.Difference(t.Label)
+		}
+		if !missing.IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsFinite reports whether the DFA accepts finitely many strings, that is whether no cycle goes
+// through a state which is both reachable and productive. As in Count, only the symbols that a
+// string can hold are followed
+func (dfa *DFA[T]) IsFinite() bool {
+	return dfa.Count() != nil
+}
+
+// Count returns the number of strings that the DFA accepts, or nil if there are infinitely many.
+// A transition on a set of symbols leads to as many strings as the set holds symbols that a string
+// can hold, so Count matches the number of strings that Strings yields without bounds
+func (dfa *DFA[T]) Count() *big.Int {
+	// the states which can neither be reached nor lead to a final state do not take part in any
+	// accepted string, and neither do their cycles
+	useful := dfa.reachableStates(runes).Intersect(dfa.productiveStates(runes))
+	if !useful.Contains(dfa.InitialState) {
+		return new(big.Int)
+	}
+
+	// the number of inputs accepted from each useful state, counted in a depth-first search. A
+	// state met again while its count is still pending closes a cycle
+	counts := make(map[T]*big.Int, useful.Cardinality())
+	pending := make(map[T]bool)
+	var count func(state T) *big.Int
+	count = func(state T) *big.Int {
+		if n, ok := counts[state]; ok {
+			return n
+		}
+		if pending[state] {
+			return nil
+		}
+		pending[state] = true
+
+		n := new(big.Int)
+		if dfa.FinalStates.Contains(state) {
+			n.SetInt64(1)
+		}
+		for _, t := range dfa.Delta[state] {
+			symbols := t.Label.Intersect(runes).Len()
+			if symbols == 0 || !useful.Contains(t.Next) {
+				continue
+			}
+			next := count(t.Next)
+			if next == nil {
+				return nil
+			}
+			n.Add(n, new(big.Int).Mul(big.NewInt(int64(symbols)), next))
+		}
+		delete(pending, state)
+		counts[state] = n
+		return n
+	}
+	return count(dfa.InitialState)
+}
+
+// runes holds the symbols that a string can hold: the Unicode code points, except for the
+// surrogate halves
+var runes = Unicode.Difference(IntervalSet{{0xD800, 0xDFFF}})
+
+// piece is an interval of symbols which all lead from a state to the same next state
+type piece[T StateLike] struct {
+	Interval
+	next T
+}
+
+// Strings returns the inputs that the DFA accepts, in shortlex order: shorter inputs first, and
+// inputs of the same length in the order of their symbols. It yields at most maxCount inputs of at
+// most maxLength symbols, a negative bound meaning no bound. The symbols which a string cannot
+// hold, such as TextBoundary, are skipped. The search only follows the transitions to states from
+// which a final state is reachable in as many steps as there are symbols left, so sparse languages
+// are enumerated without walking the inputs they reject
+func (dfa *DFA[T]) Strings(maxLength, maxCount int) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		// the symbols out of each state, in order
+		pieces := make(map[T][]piece[T], len(dfa.Delta))
+		for state, transitions := range dfa.Delta {
+			for _, t := range transitions {
+				for _, i := range t.Label.Intersect(runes) {
+					pieces[state] = append(pieces[state], piece[T]{Interval: i, next: t.Next})
+				}
+			}
+			slices.SortFunc(pieces[state], func(a, b piece[T]) int { return cmp.Compare(a.Lo, b.Lo) })
+		}
+		if maxLength < 0 && dfa.IsFinite() {
+			// an accepted input which is longer than the number of states goes through a cycle
+			maxLength = dfa.AllStates.Cardinality()
+		}
+
+		// finishing holds the states from which a final state is reachable in exactly n steps, for
+		// each n up to the current length
+		finishing := []set.Set[T]{dfa.FinalStates}
+		count := 0
+		var input []rune
+		var walk func(state T, left int) bool
+		walk = func(state T, left int) bool {
+			if left == 0 {
+				count++
+				return yield(string(input)) && count != maxCount
+			}
+			for _, p := range pieces[state] {
+				if !finishing[left-1].Contains(p.next) {
+					continue
+				}
+				for sym := p.Lo; sym <= p.Hi; sym++ {
+					input = append(input, sym)
+					more := walk(p.next, left-1)
+					input = input[:len(input)-1]
+					if !more {
+						return false
+					}
+				}
+			}
+			return true
+		}
+
+		for length := 0; maxLength < 0 || length <= maxLength; length++ {
+			if length > 0 {
+				previous := finishing[length-1]
+				states := set.NewSet[T]()
+				for state, statePieces := range pieces {
+					for _, p := range statePieces {
+						if previous.Contains(p.next) {
+							states.Add(state)
+							break
+						}
+					}
+				}
+				finishing = append(finishing, states)
+			}
+			if maxCount == 0 || finishing[length].Contains(dfa.InitialState) && !walk(dfa.InitialState, length) {
+				return
+			}
+		}
+	}
+}
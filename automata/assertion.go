@@ -0,0 +1,100 @@
+package automata
+
+// Assertion is a set of zero-width conditions on a position of the input, which only depend on the
+// symbols right before and right after it
+type Assertion uint8
+
+const (
+	// BeginText holds at the start of the input
+	BeginText Assertion = 1 << iota
+	// EndText holds at the end of the input
+	EndText
+	// BeginLine holds at the start of the input and after each newline
+	BeginLine
+	// EndLine holds at the end of the input and before each newline
+	EndLine
+	// WordBoundary holds between a word symbol and a non-word symbol, the start and end of the input
+	// counting as non-word symbols
+	WordBoundary
+	// NoWordBoundary holds wherever WordBoundary does not
+	NoWordBoundary
+)
+
+// TextBoundary stands for the missing symbols before the start and after the end of the input when
+// checking assertions
+const TextBoundary = -2
+
+// AssertionsBetween returns the assertions that hold at a position of the input, given the symbols
+// before and after it
+func AssertionsBetween(before, after Symbol) Assertion {
+	var holds Assertion
+	switch before {
+	case TextBoundary:
+		holds |= BeginText | BeginLine
+	case '\n':
+		holds |= BeginLine
+	}
+	switch after {
+	case TextBoundary:
+		holds |= EndText | EndLine
+	case '\n':
+		holds |= EndLine
+	}
+	if isWordSymbol(before) != isWordSymbol(after) {
+		holds |= WordBoundary
+	} else {
+		holds |= NoWordBoundary
+	}
+	return holds
+}
+
+// isWordSymbol reports whether a symbol is an ASCII letter, digit or underscore
+func isWordSymbol(sym Symbol) bool {
+	return sym >= '0' && sym <= '9' || sym >= 'A' && sym <= 'Z' || sym >= 'a' && sym <= 'z' || sym == '_'
+}
+
+// Reverse returns the assertions that hold at the same position of the reversed input
+func (a Assertion) Reverse() Assertion {
+	// word boundaries look both ways
+	reversed := a & (WordBoundary | NoWordBoundary)
+	pairs := [][2]Assertion{{BeginText, EndText}, {BeginLine, EndLine}}
+	for _, pair := range pairs {
+		if a&pair[0] != 0 {
+			reversed |= pair[1]
+		}
+		if a&pair[1] != 0 {
+			reversed |= pair[0]
+		}
+	}
+	return reversed
+}
+
+// assertionLabels returns the sets of symbols that satisfy other assertions than the rest of the
+// symbols, for the given assertions. Automata with assertions tell them apart as if they were
+// labels of transitions, so that the symbols of a minterm all satisfy the same assertions
+func assertionLabels(a Assertion) []IntervalSet {
+	var labels []IntervalSet
+	if a&(BeginLine|EndLine) != 0 {
+		labels = append(labels, SymbolSet('\n'))
+	}
+	if a&(WordBoundary|NoWordBoundary) != 0 {
+		labels = append(labels, NewIntervalSet(Interval{'0', '9'}, Interval{'A', 'Z'}, Interval{'_', '_'}, Interval{'a', 'z'}))
+	}
+	return labels
+}
+
+// lookbehind reduces a symbol to a representative of the symbols that satisfy the same assertions
+// when they come right before a position
+func lookbehind(sym Symbol) Symbol {
+	switch sym {
+	case TextBoundary, '\n':
+		return sym
+	}
+	if isWordSymbol(sym) {
+		return '_'
+	}
+	return otherSymbol
+}
+
+// otherSymbol stands for the symbols which are neither newlines nor word symbols
+const otherSymbol = ' '
@@ -1,8 +1,11 @@
 package automata
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
 	"strings"
+	"sync/atomic"
 
 	set "github.com/deckarep/golang-set/v2"
 )
@@ -11,15 +14,20 @@ type DFA[T StateLike] struct {
 	InitialState T
 	FinalStates  set.Set[T]
 	AllStates    set.Set[T]
-	Delta        map[T]map[Symbol]T
-	Alphabet     set.Set[Symbol]
+	// Delta holds the transitions out of each state, with a single transition per next state. The
+	// labels of the transitions out of a state are disjoint, and the symbols outside of all of them
+	// are rejected
+	Delta map[T][]Transition[T]
+
+	// table caches the dense transition table, see Table
+	table atomic.Pointer[Table[T]]
 }
 
 func NewDFA[T StateLike](
 	InitialState T,
 	FinalStates set.Set[T],
 	AllStates set.Set[T],
-	Delta map[T]map[Symbol]T,
+	Delta map[T][]Transition[T],
 ) *DFA[T] {
 	return &DFA[T]{
 		InitialState: InitialState,
@@ -38,14 +46,13 @@ func (dfa *DFA[T]) MapStates(f func(T) T) *DFA[T] {
 	for state := range dfa.AllStates.Iter() {
 		newAllStates.Add(f(state))
 	}
-	newDelta := make(map[T]map[Symbol]T)
-	for src, dest := range dfa.Delta {
-		newSrc := f(src)
-		newMap := make(map[Symbol]T, len(dest))
-		for sym, state := range dest {
-			newMap[sym] = f(state)
+	newDelta := make(map[T][]Transition[T])
+	for src, transitions := range dfa.Delta {
+		newTransitions := make([]Transition[T], 0, len(transitions))
+		for _, t := range transitions {
+			newTransitions = append(newTransitions, Transition[T]{Label: t.Label, Next: f(t.Next)})
 		}
-		newDelta[newSrc] = newMap
+		newDelta[f(src)] = newTransitions
 	}
 
 	return &DFA[T]{
@@ -53,126 +60,278 @@ func (dfa *DFA[T]) MapStates(f func(T) T) *DFA[T] {
 		FinalStates:  newFinalStates,
 		AllStates:    newAllStates,
 		Delta:        newDelta,
-		Alphabet:     dfa.Alphabet,
 	}
 }
 
 func (dfa *DFA[T]) String() string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("[Alphabet] %v\n", dfa.Alphabet.ToSlice()))
 	sb.WriteString("[Initial State] " + dfa.InitialState.String() + "\n")
 	sb.WriteString(fmt.Sprintf("[Final States] %v", dfa.FinalStates.ToSlice()) + "\n")
 	sb.WriteString(fmt.Sprintf("[ALL States] %v", dfa.AllStates.ToSlice()) + "\n")
-	for origin, mapping := range dfa.Delta {
-		for sym, dest := range mapping {
-			sb.WriteString(fmt.Sprintf("%s -> %d -> %s\n", origin.String(), sym, dest))
+	for origin, transitions := range dfa.Delta {
+		for _, t := range transitions {
+			sb.WriteString(fmt.Sprintf("%s -> %s -> %s\n", origin.String(), t.Label, t.Next))
 		}
 	}
 	return sb.String()
 }
 
+// Accepts reports whether the DFA accepts the input. It runs on the dense transition table
 func (dfa *DFA[T]) Accepts(input []Symbol) bool {
-	currentState := dfa.InitialState
-	for _, symbol := range input {
+	return dfa.Table().Accepts(input)
+}
+
+// Table returns the dense transition table of the DFA. It is built on the first call and then
+// reused, so the DFA must not be modified afterwards
+func (dfa *DFA[T]) Table() *Table[T] {
+	if table := dfa.table.Load(); table != nil {
+		return table
+	}
+	table := newTable(dfa)
+	dfa.table.Store(table)
+	return table
+}
+
+// Step returns the state reached from a state by reading a symbol
+func (dfa *DFA[T]) Step(state T, symbol Symbol) (T, bool) {
+	for _, t := range dfa.Delta[state] {
+		if t.Label.Contains(symbol) {
+			return t.Next, true
+		}
+	}
+	var none T
+	return none, false
+}
 
-		if nextState, ok := dfa.Delta[currentState][symbol]; ok {
-			currentState = nextState
-			continue
+// Minterms splits the symbols of the labels of the transitions into the largest sets of symbols
+// that take the same transitions out of every state
+func (dfa *DFA[T]) Minterms() []IntervalSet {
+	return dfa.partition().minterms
+}
+
+func (dfa *DFA[T]) partition() *partition {
+	var labels []IntervalSet
+	for _, transitions := range dfa.Delta {
+		for _, t := range transitions {
+			labels = append(labels, t.Label)
 		}
-		return false
 	}
-	return dfa.FinalStates.Contains(currentState)
+	return newPartition(labels)
 }
 
 // Thompson's algorithm should not generate any unreachable state, but this is general automata functionality
 func (dfa *DFA[T]) RemoveUnreachableStates() *DFA[T] {
-	reachableStates := set.NewSet(dfa.InitialState)
-	newStates := set.NewSet(dfa.InitialState)
+	reachableStates := dfa.reachableStates(nil)
+	unreachableStates := dfa.AllStates.Difference(reachableStates)
+
+	// since unreachable states have no transition into them, we only have to update the set of initial and final states
+	dfa.AllStates = reachableStates
+	dfa.FinalStates = dfa.FinalStates.Difference(unreachableStates)
+	dfa.table.Store(nil)
+	return dfa
+}
+
+// reachableStates returns the states reachable from the initial state, only following the
+// transitions on symbols of an alphabet, or all of them if the alphabet is nil
+func (dfa *DFA[T]) reachableStates(alphabet IntervalSet) set.Set[T] {
+	return reach([]T{dfa.InitialState}, func(state T) []T {
+		next := make([]T, 0, len(dfa.Delta[state]))
+		for _, t := range dfa.Delta[state] {
+			if alphabet == nil || !t.Label.Intersect(alphabet).IsEmpty() {
+				next = append(next, t.Next)
+			}
+		}
+		return next
+	})
+}
+
+// productiveStates returns the states from which some final state is reachable, only following
+// the transitions on symbols of an alphabet, or all of them if the alphabet is nil
+func (dfa *DFA[T]) productiveStates(alphabet IntervalSet) set.Set[T] {
+	previous := make(map[T][]T)
+	for state, transitions := range dfa.Delta {
+		for _, t := range transitions {
+			if alphabet == nil || !t.Label.Intersect(alphabet).IsEmpty() {
+				previous[t.Next] = append(previous[t.Next], state)
+			}
+		}
+	}
+	return reach(dfa.FinalStates.ToSlice(), func(state T) []T { return previous[state] })
+}
+
+// reach returns the states reachable from the given states, following the edges returned by next
+func reach[T StateLike](from []T, next func(T) []T) set.Set[T] {
+	reachableStates := set.NewSet(from...)
+	newStates := set.NewSet(from...)
 
 	for !newStates.IsEmpty() {
 		temp := set.NewSet[T]()
 		for state := range newStates.Iter() {
-			for sym := range dfa.Alphabet.Iter() {
-				temp.Add(dfa.Delta[state][sym])
-			}
+			temp.Append(next(state)...)
 		}
 		newStates = temp.Difference(reachableStates)
 		reachableStates = reachableStates.Union(newStates)
 	}
-	unreachableStates := dfa.AllStates.Difference(reachableStates)
-
-	// since unreachable states have no transition into them, we only have to update the set of initial and final states
-	dfa.AllStates = reachableStates
-	dfa.FinalStates = dfa.FinalStates.Difference(unreachableStates)
-	return dfa
+	return reachableStates
 }
 
-// Hopcroft's algorithm for DFA minimization
+// Hopcroft's algorithm for DFA minimization. The states are told apart by the transitions they take
+// on each minterm, and the symbols without a transition lead to an implicit dead state
 func (dfa *DFA[T]) Minimize() *DFA[T] {
-	// the partitions are groupings of identical states from the original DFA
-	partitions := []set.Set[T]{dfa.FinalStates, dfa.AllStates.Difference(dfa.FinalStates)}
-	changed := true
-	alphabetSymbols := dfa.Alphabet.ToSlice()
-	// wait until the number of partitions stablizes
-	for changed {
-		changed = false
-		newPartitions := make([]set.Set[T], 0, len(partitions))
-
-		stateToPartitionMap := make(map[T]int)
-		for i, partition := range partitions {
-			for state := range partition.Iter() {
-				stateToPartitionMap[state] = i
+	states := dfa.AllStates.ToSlice()
+	slices.Sort(states)
+	numbers := make(map[T]int, len(states))
+	for i, state := range states {
+		numbers[state] = i
+	}
+	p := dfa.partition()
+	classes := len(p.minterms)
+
+	// previous holds, for each minterm and each state, the states that lead to it on the minterm.
+	// The dead state comes after all the states of the DFA
+	dead := len(states)
+	previous := make([][][]int, classes)
+	for class := range previous {
+		previous[class] = make([][]int, len(states)+1)
+	}
+	for i, state := range states {
+		covered := make([]bool, classes)
+		for _, t := range dfa.Delta[state] {
+			for _, class := range p.classesOf(t.Label) {
+				covered[class] = true
+				next := numbers[t.Next]
+				previous[class][next] = append(previous[class][next], i)
 			}
 		}
+		for class, ok := range covered {
+			if !ok {
+				previous[class][dead] = append(previous[class][dead], i)
+			}
+		}
+	}
+	for class := range previous {
+		previous[class][dead] = append(previous[class][dead], dead)
+	}
 
-		// iterate through all partitions
-		for _, partition := range partitions {
-			subPartitions := make(map[string]set.Set[T])
-			// iterate through all states in the current partition
-			for state := range partition.Iter() {
-				// build up key for merged states
-				signature := make([]int, dfa.Alphabet.Cardinality())
-				// we want to see how the state behaves for all symbols in the alphabet
-				for i, sym := range alphabetSymbols {
-					if nextState, ok := dfa.Delta[state][sym]; ok {
-						signature[i] = stateToPartitionMap[nextState]
-						continue
-					}
-					// if no transition, then mark that as well
-					signature[i] = -1
-				}
+	// the blocks of the partition are ranges of elements, so that a block is split by moving the
+	// states to split off to its front. Initially, the final states are split from the non-final ones
+	type block struct{ start, end, marked int }
+	elements := make([]int, 0, len(states)+1)
+	for i, state := range states {
+		if dfa.FinalStates.Contains(state) {
+			elements = append(elements, i)
+		}
+	}
+	finals := len(elements)
+	for i, state := range states {
+		if !dfa.FinalStates.Contains(state) {
+			elements = append(elements, i)
+		}
+	}
+	elements = append(elements, dead)
+	var blocks []block
+	if finals > 0 {
+		blocks = append(blocks, block{start: 0, end: finals})
+	}
+	blocks = append(blocks, block{start: finals, end: len(elements)})
+	blockOf := make([]int, len(elements))
+	position := make([]int, len(elements))
+	for b, bl := range blocks {
+		for e := bl.start; e < bl.end; e++ {
+			blockOf[elements[e]] = b
+			position[elements[e]] = e
+		}
+	}
 
-				key := fmt.Sprint(signature)
-				if subPartitions[key] == nil {
-					subPartitions[key] = set.NewSet[T]()
-				}
-				// at the end, we know the partition where that state leads for each symbol
-				// For example 1 -> "a" -> 2, 1->"b"->1 with alphabet abc has the following
-				// signature: [2,1,-1]
-				subPartitions[key].Add(state)
+	// the worklist holds the blocks and minterms to split the other blocks with. Only the smaller half
+	// of a split block has to be added, as splitting with the whole block has been or will be done
+	type splitter struct{ block, class int }
+	var worklist []splitter
+	queued := make(map[splitter]bool)
+	enqueue := func(s splitter) {
+		if !queued[s] {
+			queued[s] = true
+			worklist = append(worklist, s)
+		}
+	}
+	if len(blocks) == 2 {
+		smaller := 0
+		if blocks[1].end-blocks[1].start < blocks[0].end-blocks[0].start {
+			smaller = 1
+		}
+		for class := range classes {
+			enqueue(splitter{smaller, class})
+		}
+	}
+
+	var leading, touched []int
+	for len(worklist) > 0 {
+		s := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		delete(queued, s)
+
+		// the states leading into the splitter are gathered before any block is split
+		leading = leading[:0]
+		for e := blocks[s.block].start; e < blocks[s.block].end; e++ {
+			leading = append(leading, previous[s.class][elements[e]]...)
+		}
+		touched = touched[:0]
+		for _, state := range leading {
+			b := blockOf[state]
+			bl := &blocks[b]
+			if position[state] < bl.start+bl.marked {
+				continue
 			}
+			if bl.marked == 0 {
+				touched = append(touched, b)
+			}
+			// swap the state with the first unmarked one
+			other := elements[bl.start+bl.marked]
+			elements[position[state]], elements[bl.start+bl.marked] = other, state
+			position[other], position[state] = position[state], bl.start+bl.marked
+			bl.marked++
+		}
 
-			// all states that have transitions inside the same partitions can form their own partition
-			for _, subPartition := range subPartitions {
-				newPartitions = append(newPartitions, subPartition)
+		for _, b := range touched {
+			bl := &blocks[b]
+			marked := bl.marked
+			bl.marked = 0
+			if marked == bl.end-bl.start {
+				continue
+			}
+			// the marked states leave for a new block
+			split := len(blocks)
+			blocks = append(blocks, block{start: bl.start, end: bl.start + marked})
+			bl = &blocks[b]
+			bl.start += marked
+			for e := blocks[split].start; e < blocks[split].end; e++ {
+				blockOf[elements[e]] = split
+			}
+			smaller := split
+			if bl.end-bl.start < marked {
+				smaller = b
+			}
+			for class := range classes {
+				if queued[splitter{b, class}] {
+					enqueue(splitter{split, class})
+				} else {
+					enqueue(splitter{smaller, class})
+				}
 			}
-			changed = len(newPartitions) != len(partitions)
 		}
-		partitions = newPartitions
 	}
 
-	// create mapping based on partition groups - no need to generate new states, take a random one
-	stateMap := make(map[T]T, len(partitions))
-	for _, p := range partitions {
-		joinState, ok := p.Pop()
+	// create mapping based on partition groups - no need to generate new states, take the first one
+	joinStates := make(map[int]T, len(blocks))
+	stateMap := make(map[T]T, len(states))
+	for i, state := range states {
+		joinState, ok := joinStates[blockOf[i]]
 		if !ok {
-			continue
-		}
-		stateMap[joinState] = joinState
-		for state := range p.Iter() {
-			stateMap[state] = joinState
+			joinState = state
+			joinStates[blockOf[i]] = state
 		}
+		stateMap[state] = joinState
 	}
 	newFinalStates := set.NewSet[T]()
 	for st := range dfa.FinalStates.Iter() {
@@ -182,22 +341,36 @@ func (dfa *DFA[T]) Minimize() *DFA[T] {
 	for st := range dfa.AllStates.Iter() {
 		newAllStates.Add(stateMap[st])
 	}
-	newDelta := make(map[T]map[Symbol]T)
-	for originState, symMapping := range dfa.Delta {
-		newOriginState := stateMap[originState]
-		if newDelta[newOriginState] == nil {
-			newDelta[newOriginState] = make(map[Symbol]T)
-		}
-		for sym, destinationState := range symMapping {
-			newDestinationState := stateMap[destinationState]
-			newDelta[newOriginState][sym] = newDestinationState
+	// the states of a partition all take the same transitions, up to the partitions they lead to
+	newDelta := make(map[T][]Transition[T])
+	for _, joinState := range joinStates {
+		var transitions []Transition[T]
+		for _, t := range dfa.Delta[joinState] {
+			transitions = append(transitions, Transition[T]{Label: t.Label, Next: stateMap[t.Next]})
 		}
+		newDelta[joinState] = mergeTransitions(transitions)
 	}
 	return &DFA[T]{
 		InitialState: stateMap[dfa.InitialState],
 		FinalStates:  newFinalStates,
 		AllStates:    newAllStates,
 		Delta:        newDelta,
-		Alphabet:     dfa.Alphabet,
 	}
 }
+
+// mergeTransitions joins the transitions to the same state into a single one, labelled with the
+// union of their labels. The transitions are sorted by their first symbol
+func mergeTransitions[T StateLike](transitions []Transition[T]) []Transition[T] {
+	labels := make(map[T][]Interval)
+	for _, t := range transitions {
+		if !t.Label.IsEmpty() {
+			labels[t.Next] = append(labels[t.Next], t.Label...)
+		}
+	}
+	merged := make([]Transition[T], 0, len(labels))
+	for next, intervals := range labels {
+		merged = append(merged, Transition[T]{Label: NewIntervalSet(intervals...), Next: next})
+	}
+	slices.SortFunc(merged, func(a, b Transition[T]) int { return cmp.Compare(a.Label[0].Lo, b.Label[0].Lo) })
+	return merged
+}
@@ -0,0 +1,263 @@
+package automata
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Interval is an inclusive range of symbols
+type Interval struct {
+	Lo Symbol
+	Hi Symbol
+}
+
+// IntervalSet is a set of symbols, kept as sorted intervals which neither overlap nor touch, so
+// that two sets with the same symbols are equal
+type IntervalSet []Interval
+
+// Unicode holds all the Unicode code points, which wildcards and negated sets range over
+var Unicode = IntervalSet{{0, unicode.MaxRune}}
+
+// ASCII holds the ASCII characters
+var ASCII = IntervalSet{{0, unicode.MaxASCII}}
+
+// Bytes holds the symbols of a single byte, for automata run on raw bytes
+var Bytes = IntervalSet{{0, 0xFF}}
+
+// NewIntervalSet builds the set of the symbols in the given intervals
+func NewIntervalSet(intervals ...Interval) IntervalSet {
+	sorted := slices.Clone(intervals)
+	slices.SortFunc(sorted, func(a, b Interval) int { return cmp.Compare(a.Lo, b.Lo) })
+
+	var merged IntervalSet
+	for _, i := range sorted {
+		if i.Lo > i.Hi {
+			continue
+		}
+		if n := len(merged); n > 0 && i.Lo <= merged[n-1].Hi+1 {
+			merged[n-1].Hi = max(merged[n-1].Hi, i.Hi)
+			continue
+		}
+		merged = append(merged, i)
+	}
+	return merged
+}
+
+// SymbolSet builds the set of the given symbols
+func SymbolSet(symbols ...Symbol) IntervalSet {
+	intervals := make([]Interval, 0, len(symbols))
+	for _, sym := range symbols {
+		intervals = append(intervals, Interval{sym, sym})
+	}
+	return NewIntervalSet(intervals...)
+}
+
+// IsEmpty reports whether the set holds no symbol
+func (s IntervalSet) IsEmpty() bool {
+	return len(s) == 0
+}
+
+// Len returns the number of symbols in the set
+func (s IntervalSet) Len() int {
+	n := 0
+	for _, i := range s {
+		n += int(i.Hi-i.Lo) + 1
+	}
+	return n
+}
+
+// Contains reports whether a symbol belongs to the set
+func (s IntervalSet) Contains(sym Symbol) bool {
+	i := s.search(sym)
+	return i < len(s) && s[i].Lo <= sym
+}
+
+// search returns the index of the first interval which does not end before a symbol
+func (s IntervalSet) search(sym Symbol) int {
+	i, _ := slices.BinarySearchFunc(s, sym, func(i Interval, sym Symbol) int {
+		if i.Hi < sym {
+			return -1
+		}
+		return 0
+	})
+	return i
+}
+
+// Union returns the set of the symbols in either s or other
+func (s IntervalSet) Union(other IntervalSet) IntervalSet {
+	return NewIntervalSet(slices.Concat(s, other)...)
+}
+
+// Intersect returns the set of the symbols in both s and other
+func (s IntervalSet) Intersect(other IntervalSet) IntervalSet {
+	var intervals IntervalSet
+	for i, j := 0, 0; i < len(s) && j < len(other); {
+		a, b := s[i], other[j]
+		if lo, hi := max(a.Lo, b.Lo), min(a.Hi, b.Hi); lo <= hi {
+			intervals = append(intervals, Interval{lo, hi})
+		}
+		// the interval which ends first cannot overlap any further interval of the other set
+		if a.Hi < b.Hi {
+			i++
+		} else {
+			j++
+		}
+	}
+	return intervals
+}
+
+// Difference returns the set of the symbols in s but not in other
+func (s IntervalSet) Difference(other IntervalSet) IntervalSet {
+	var intervals IntervalSet
+	j := 0
+	for _, i := range s {
+		// the intervals of other which end before this one cannot overlap the next ones either
+		for j < len(other) && other[j].Hi < i.Lo {
+			j++
+		}
+		lo := i.Lo
+		for k := j; k < len(other) && other[k].Lo <= i.Hi; k++ {
+			if other[k].Lo > lo {
+				intervals = append(intervals, Interval{lo, other[k].Lo - 1})
+			}
+			lo = max(lo, other[k].Hi+1)
+		}
+		if lo <= i.Hi {
+			intervals = append(intervals, Interval{lo, i.Hi})
+		}
+	}
+	return intervals
+}
+
+// Equal reports whether both sets hold the same symbols
+func (s IntervalSet) Equal(other IntervalSet) bool {
+	return slices.Equal(s, other)
+}
+
+func (s IntervalSet) String() string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for n, i := range s {
+		if n > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(symbolString(i.Lo))
+		if i.Hi > i.Lo {
+			sb.WriteString("-" + symbolString(i.Hi))
+		}
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+func symbolString(sym Symbol) string {
+	switch {
+	case sym == TextBoundary:
+		return "<boundary>"
+	case sym >= 0 && unicode.IsPrint(sym) && sym != ' ' && sym != '-' && sym != '[' && sym != ']':
+		return string(sym)
+	}
+	return strconv.QuoteRuneToASCII(sym)
+}
+
+// partition splits the symbols of a set of labels into minterms: the largest sets of symbols which
+// no label tells apart, as each label either holds all of the symbols of a minterm or none of them.
+// Automata labelled with the labels can then be run on one representative symbol per minterm
+type partition struct {
+	minterms []IntervalSet
+	// starts holds the first symbol of each of the elementary intervals between the bounds of the
+	// labels, and classes the minterm that each of them belongs to, or -1 for the symbols outside
+	// of all the labels
+	starts  []Symbol
+	classes []int
+}
+
+func newPartition(labels []IntervalSet) *partition {
+	// the labels of automata are mostly the same few sets
+	distinct := make(map[string]IntervalSet, len(labels))
+	var bounds []Symbol
+	for _, label := range labels {
+		key := label.String()
+		if _, ok := distinct[key]; ok || label.IsEmpty() {
+			continue
+		}
+		distinct[key] = label
+		for _, i := range label {
+			bounds = append(bounds, i.Lo, i.Hi+1)
+		}
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	// an elementary interval runs from a bound up to the next one, and each label holds either all
+	// of its symbols or none of them. The elementary intervals held by the same labels form a
+	// minterm
+	held := make([][]int, len(bounds))
+	keys := slices.Sorted(maps.Keys(distinct))
+	for n, key := range keys {
+		for _, i := range distinct[key] {
+			first, _ := slices.BinarySearch(bounds, i.Lo)
+			for e := first; e < len(bounds) && bounds[e] <= i.Hi; e++ {
+				held[e] = append(held[e], n)
+			}
+		}
+	}
+
+	p := &partition{starts: bounds, classes: make([]int, len(bounds))}
+	mintermOf := make(map[string]int)
+	var signature []byte
+	var intervals [][]Interval
+	for e := range bounds {
+		if len(held[e]) == 0 {
+			p.classes[e] = -1
+			continue
+		}
+		signature = signature[:0]
+		for _, n := range held[e] {
+			signature = strconv.AppendInt(signature, int64(n), 10)
+			signature = append(signature, ',')
+		}
+		class, ok := mintermOf[string(signature)]
+		if !ok {
+			class = len(intervals)
+			mintermOf[string(signature)] = class
+			intervals = append(intervals, nil)
+		}
+		p.classes[e] = class
+		// the last bound is always the end of a label, so a held interval is never the last one
+		intervals[class] = append(intervals[class], Interval{bounds[e], bounds[e+1] - 1})
+	}
+	for _, i := range intervals {
+		p.minterms = append(p.minterms, NewIntervalSet(i...))
+	}
+	return p
+}
+
+// classOf returns the minterm that a symbol belongs to, or -1 if it is outside of all the labels
+func (p *partition) classOf(sym Symbol) int {
+	e, found := slices.BinarySearch(p.starts, sym)
+	if !found {
+		e--
+	}
+	if e < 0 {
+		return -1
+	}
+	return p.classes[e]
+}
+
+// classesOf returns the sorted minterms that make up a label of the partition
+func (p *partition) classesOf(label IntervalSet) []int {
+	var classes []int
+	for _, i := range label {
+		first, _ := slices.BinarySearch(p.starts, i.Lo)
+		for e := first; e < len(p.starts) && p.starts[e] <= i.Hi; e++ {
+			classes = append(classes, p.classes[e])
+		}
+	}
+	slices.Sort(classes)
+	return slices.Compact(classes)
+}
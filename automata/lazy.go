@@ -0,0 +1,184 @@
+package automata
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// maxCachedStates is the number of states that a lazy DFA keeps. Past it, the states which are not
+// cached are built again on every step, which amounts to simulating the NFA
+const maxCachedStates = 10000
+
+// lazyDFA is the delayed DFA of an NFA, as built by ToSearchDFA, whose states and transitions are
+// only built when an input goes through them. Patterns with exponentially many DFA states, such as
+// .{30}x reversed, can then be searched in time linear in the size of the input. It is safe for
+// concurrent use
+type lazyDFA[T StateLike] struct {
+	nfa *NFA[T]
+	// numbers maps the NFA states to the bits of the sets returned by around
+	numbers map[T]int
+	// dead is the state without any transition, which rejects all inputs
+	dead *lazyState[T]
+	// starts holds the initial state after each kind of symbol before the input, indexed like
+	// lookbehinds
+	starts [len(lookbehinds)]atomic.Pointer[lazyState[T]]
+
+	// the classes of the ASCII symbols are looked up directly, and the others in the partition
+	ascii     [128]int
+	p         *partition
+	textClass int
+
+	// the determinizer and the cache are only used under the lock
+	mu    sync.Mutex
+	d     *determinizer[T]
+	cache map[string]*lazyState[T]
+}
+
+// lazyState is a state of a lazy DFA. The cached states hold their transitions to other cached
+// states once they are built, while the states built past the limit of the cache hold none
+type lazyState[T StateLike] struct {
+	subset[T]
+	final bool
+	next  []atomic.Pointer[lazyState[T]]
+	// around holds the sets returned by around, indexed like lookbehinds
+	around [len(lookbehinds)]atomic.Pointer[[]uint64]
+}
+
+// lookbehinds holds the symbols that lookbehind reduces the symbols to
+var lookbehinds = [...]Symbol{TextBoundary, '\n', '_', otherSymbol}
+
+func newLazyDFA[T StateLike](nfa *NFA[T], numbers map[T]int) *lazyDFA[T] {
+	d := newDeterminizer(nfa, true)
+	l := &lazyDFA[T]{
+		nfa:       nfa,
+		numbers:   numbers,
+		dead:      &lazyState[T]{},
+		p:         d.p,
+		textClass: d.textClass,
+		d:         d,
+		cache:     make(map[string]*lazyState[T]),
+	}
+	for sym := range l.ascii {
+		l.ascii[sym] = d.p.classOf(Symbol(sym))
+	}
+	return l
+}
+
+// class returns the class of a symbol, or -1 if it has no transition at all
+func (l *lazyDFA[T]) class(sym Symbol) int {
+	switch {
+	case sym >= 0 && int(sym) < len(l.ascii):
+		return l.ascii[sym]
+	case sym == TextBoundary:
+		return l.textClass
+	}
+	return l.p.classOf(sym)
+}
+
+// start returns the state that the DFA reaches by reading the symbol before the input
+func (l *lazyDFA[T]) start(before Symbol) *lazyState[T] {
+	i := lookbehindIndex(before)
+	if s := l.starts[i].Load(); s != nil {
+		return s
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.state(l.d.start(before))
+	l.starts[i].Store(s)
+	return s
+}
+
+// step returns the state reached from a state by reading a symbol
+func (l *lazyDFA[T]) step(s *lazyState[T], sym Symbol) *lazyState[T] {
+	class := l.class(sym)
+	if class < 0 {
+		return l.dead
+	}
+	if s.next != nil {
+		if next := s.next[class].Load(); next != nil {
+			return next
+		}
+	}
+	return l.build(s, class)
+}
+
+// build builds the transition of a state on a class
+func (l *lazyDFA[T]) build(s *lazyState[T], class int) *lazyState[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// another search may have built it in the meantime
+	if s.next != nil {
+		if next := s.next[class].Load(); next != nil {
+			return next
+		}
+	}
+
+	next := l.dead
+	if sub, ok := l.d.next(s.subset, class, nil); ok {
+		next = l.state(sub)
+	}
+	if s.next != nil && (next == l.dead || next.next != nil) {
+		s.next[class].Store(next)
+	}
+	return next
+}
+
+// state returns the state of a subset, which is cached if the cache is not full yet
+func (l *lazyDFA[T]) state(sub subset[T]) *lazyState[T] {
+	key := l.d.key(sub)
+	if s, ok := l.cache[key]; ok {
+		return s
+	}
+	s := &lazyState[T]{subset: sub, final: l.d.final(sub)}
+	if len(l.cache) < maxCachedStates {
+		s.next = make([]atomic.Pointer[lazyState[T]], l.textClass+1)
+		l.cache[key] = s
+	}
+	return s
+}
+
+// cached reports whether a state is kept by the DFA
+func (s *lazyState[T]) cached() bool {
+	return s.next != nil
+}
+
+// around returns the NFA states that a state stands for at a position of the input, given the
+// symbol after the position: its states along with the states reached from them by the epsilon
+// transitions which can be followed there. The states are returned as a set of their numbers
+func (l *lazyDFA[T]) around(s *lazyState[T], after Symbol) []uint64 {
+	i := lookbehindIndex(after)
+	if around := s.around[i].Load(); around != nil {
+		return *around
+	}
+	around := make([]uint64, (len(l.numbers)+63)/64)
+	for _, state := range l.nfa.closure(s.states, AssertionsBetween(s.before, after)) {
+		if n, ok := l.numbers[state]; ok {
+			around[n/64] |= 1 << (n % 64)
+		}
+	}
+	s.around[i].Store(&around)
+	return around
+}
+
+// lookbehindIndex returns the index in lookbehinds of the symbol that a symbol reduces to
+func lookbehindIndex(sym Symbol) int {
+	switch lookbehind(sym) {
+	case TextBoundary:
+		return 0
+	case '\n':
+		return 1
+	case '_':
+		return 2
+	}
+	return 3
+}
+
+// overlap reports whether two sets of numbers have a number in common
+func overlap(a, b []uint64) bool {
+	for i := range a {
+		if a[i]&b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,9 @@ package automata
 
 import (
 	"fmt"
+	"maps"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/bogdan-deac/regex/common/generator"
@@ -11,27 +13,47 @@ import (
 )
 
 type NFA[T StateLike] struct {
-	IntialState        T
-	FinalStates        set.Set[T]
-	AllStates          set.Set[T]
-	Alphabet           set.Set[Symbol]
-	Delta              map[T]map[Symbol][]T
+	IntialState T
+	FinalStates set.Set[T]
+	AllStates   set.Set[T]
+	// Delta holds the transitions out of each state on symbols, each labelled with the set of
+	// symbols it is taken on. The labels of the transitions out of a state may overlap
+	Delta              map[T][]Transition[T]
 	EpsilonTransitions map[T][]T
+	// Tags maps the states that record the position of the input when they are entered to the
+	// submatch slot they record it in
+	Tags map[T]int
+	// Assertions maps the states whose epsilon transitions can only be followed at the positions of
+	// the input where all their assertions hold
+	Assertions map[T]Assertion
+	// Spans maps the states to the span of the pattern that the regex node which created them was
+	// parsed from
+	Spans map[T]Span
+}
+
+// Span is a range of byte offsets of a pattern, from Start up to End excluded
+type Span struct {
+	Start int
+	End   int
+}
+
+// Transition is taken on every symbol of its label
+type Transition[T StateLike] struct {
+	Label IntervalSet
+	Next  T
 }
 
 func NewNFA[T StateLike](
 	IntialState T,
 	FinalStates set.Set[T],
 	AllStates set.Set[T],
-	Alphabet set.Set[Symbol],
-	Delta map[T]map[Symbol][]T,
+	Delta map[T][]Transition[T],
 	EpsilonTransitions map[T][]T,
 ) *NFA[T] {
 	return &NFA[T]{
 		IntialState:        IntialState,
 		FinalStates:        FinalStates,
 		AllStates:          AllStates,
-		Alphabet:           Alphabet,
 		Delta:              Delta,
 		EpsilonTransitions: EpsilonTransitions,
 	}
@@ -68,14 +90,13 @@ func (nfa *NFA[T]) EpsilonClosures() map[T]set.Set[T] {
 func (nfa *NFA[T]) String() string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("[Alphabet] %v\n", nfa.Alphabet.ToSlice()))
 	sb.WriteString("[Initial State] " + nfa.IntialState.String() + "\n")
 	sb.WriteString(fmt.Sprintf("[Final States] %v", nfa.FinalStates.ToSlice()) + "\n")
 	sb.WriteString(fmt.Sprintf("[ALL States] %v", nfa.AllStates.ToSlice()) + "\n")
 	sb.WriteString("[DELTA]\n")
-	for origin, mapping := range nfa.Delta {
-		for sym, dest := range mapping {
-			sb.WriteString(fmt.Sprintf("%s -> %d -> %s\n", origin.String(), sym, dest))
+	for origin, transitions := range nfa.Delta {
+		for _, t := range transitions {
+			sb.WriteString(fmt.Sprintf("%s -> %s -> %s\n", origin.String(), t.Label, t.Next))
 		}
 	}
 	sb.WriteString("[EPS_TRANSITIONS]\n")
@@ -85,132 +106,430 @@ func (nfa *NFA[T]) String() string {
 	return sb.String()
 }
 
-func (nfa *NFA[T]) RemoveWildcards() {
-	nfa.Alphabet.Remove(Wildcard)
-	hasWildcard := false
-	for start, mapping := range nfa.Delta {
-		for sym, dest := range mapping {
-			if sym != Wildcard {
-				continue
-			}
-			hasWildcard = true
-			for _, alphabetSym := range ASCIIChars {
-				for _, newSt := range dest {
-					if !slices.Contains(mapping[alphabetSym], newSt) {
-						mapping[alphabetSym] = append(mapping[alphabetSym], newSt)
-					}
-				}
-				delete(mapping, sym)
-			}
-			nfa.Delta[start] = mapping
+// Reverse builds an NFA for the reversed language. All transitions are flipped and a new initial
+// state is generated, with epsilon transitions to each of the original final states
+func (nfa *NFA[T]) Reverse(g generator.Generator[T]) *NFA[T] {
+	initialState := g.Generate()
+
+	delta := make(map[T][]Transition[T])
+	for origin, transitions := range nfa.Delta {
+		for _, t := range transitions {
+			delta[t.Next] = append(delta[t.Next], Transition[T]{Label: t.Label, Next: origin})
 		}
 	}
-	if hasWildcard {
-		nfa.Alphabet.Append(ASCIIChars...)
+
+	epsilonTransitions := make(map[T][]T)
+	for origin, dest := range nfa.EpsilonTransitions {
+		for _, st := range dest {
+			epsilonTransitions[st] = append(epsilonTransitions[st], origin)
+		}
+	}
+	epsilonTransitions[initialState] = nfa.FinalStates.ToSlice()
+
+	// the start of the reversed input is the end of the original one
+	assertions := make(map[T]Assertion, len(nfa.Assertions))
+	for state, a := range nfa.Assertions {
+		assertions[state] = a.Reverse()
+	}
+
+	allStates := nfa.AllStates.Clone()
+	allStates.Add(initialState)
+	return &NFA[T]{
+		IntialState:        initialState,
+		FinalStates:        set.NewSet(nfa.IntialState),
+		AllStates:          allStates,
+		Delta:              delta,
+		EpsilonTransitions: epsilonTransitions,
+		Assertions:         assertions,
+		Spans:              maps.Clone(nfa.Spans),
 	}
 }
 
-// implemented using the subset construction algorithm
-func (nfa *NFA[T]) ToDFA(g generator.Generator[T]) *DFA[T] {
+// Unanchored builds an NFA that accepts every input with a suffix in the language of the original
+// NFA. It is the original NFA preceded by a state that loops on any symbol
+func (nfa *NFA[T]) Unanchored(g generator.Generator[T]) *NFA[T] {
+	initialState := g.Generate()
 
-	epsClosures := nfa.EpsilonClosures()
-	nfa.RemoveWildcards()
-	// use a trie for generating DFA states for sets of NFA states
-	mergeStates := make(map[string]T)
+	delta := maps.Clone(nfa.Delta)
+	if delta == nil {
+		delta = make(map[T][]Transition[T])
+	}
+	delta[initialState] = []Transition[T]{{Label: Unicode, Next: initialState}}
 
-	dfaAllStates := set.NewSet[T]()
-	dfaFinalStates := set.NewSet[T]()
+	epsilonTransitions := maps.Clone(nfa.EpsilonTransitions)
+	if epsilonTransitions == nil {
+		epsilonTransitions = make(map[T][]T)
+	}
+	epsilonTransitions[initialState] = []T{nfa.IntialState}
 
-	dfaDelta := make(map[T]map[Symbol]T)
+	allStates := nfa.AllStates.Clone()
+	allStates.Add(initialState)
+	return &NFA[T]{
+		IntialState:        initialState,
+		FinalStates:        nfa.FinalStates.Clone(),
+		AllStates:          allStates,
+		Delta:              delta,
+		EpsilonTransitions: epsilonTransitions,
+		Tags:               maps.Clone(nfa.Tags),
+		Assertions:         maps.Clone(nfa.Assertions),
+		Spans:              maps.Clone(nfa.Spans),
+	}
+}
 
-	initialStateWithClosure := epsClosures[nfa.IntialState]
+// next returns the states reached from a state by reading a symbol, without following epsilon
+// transitions
+func (nfa *NFA[T]) next(state T, symbol Symbol) []T {
+	var nextStates []T
+	for _, t := range nfa.Delta[state] {
+		if t.Label.Contains(symbol) {
+			nextStates = append(nextStates, t.Next)
+		}
+	}
+	return nextStates
+}
 
-	sliceISWC := initialStateWithClosure.ToSlice()
-	slices.Sort(sliceISWC)
+// closure returns the sorted states reachable from the given ones via epsilon transitions. The
+// epsilon transitions of states with assertions are only followed if all of their assertions hold
+func (nfa *NFA[T]) closure(states []T, holds Assertion) []T {
+	reached := set.NewThreadUnsafeSet[T]()
+	toCheck := slices.Clone(states)
+	for len(toCheck) > 0 {
+		state := toCheck[len(toCheck)-1]
+		toCheck = toCheck[:len(toCheck)-1]
+		if !reached.Add(state) {
+			continue
+		}
+		if a, ok := nfa.Assertions[state]; ok && a&holds != a {
+			continue
+		}
+		toCheck = append(toCheck, nfa.EpsilonTransitions[state]...)
+	}
+	closed := reached.ToSlice()
+	slices.Sort(closed)
+	return closed
+}
 
-	dfaInitialState := g.Generate()
-	mergeStates[fmt.Sprint(sliceISWC)] = dfaInitialState
+// Submatches simulates the NFA on input[start:end] and returns the submatch slots recorded by the
+// highest priority path that accepts exactly that span, or nil if there is none. Paths are
+// prioritised by the order of the epsilon transitions, and slots which are never recorded are -1.
+// The first two slots hold the span itself
+func (nfa *NFA[T]) Submatches(input []Symbol, start, end, slots int) []int {
+	type thread struct {
+		state T
+		slots []int
+	}
 
-	dfaAllStates.Add(dfaInitialState)
+	// the assertions that hold at each position depend on the symbols around it
+	symbolAt := func(pos int) Symbol {
+		if pos < 0 || pos >= len(input) {
+			return TextBoundary
+		}
+		return input[pos]
+	}
 
-	// if any state in the epsilon-closed set, add the newly generated state to the final states as well
-	if nfa.FinalStates.ContainsAny(sliceISWC...) {
-		dfaFinalStates.Add(dfaInitialState)
+	// addThread adds a thread and all the threads reachable from it via epsilon transitions. States
+	// that are already taken by a thread with a higher priority are skipped
+	var addThread func(threads []thread, visited set.Set[T], state T, recorded []int, pos int) []thread
+	addThread = func(threads []thread, visited set.Set[T], state T, recorded []int, pos int) []thread {
+		if !visited.Add(state) {
+			return threads
+		}
+		if slot, ok := nfa.Tags[state]; ok && slot < len(recorded) {
+			recorded = slices.Clone(recorded)
+			recorded[slot] = pos
+		}
+		threads = append(threads, thread{state: state, slots: recorded})
+		if a, ok := nfa.Assertions[state]; ok && a&AssertionsBetween(symbolAt(pos-1), symbolAt(pos)) != a {
+			return threads
+		}
+		for _, next := range nfa.EpsilonTransitions[state] {
+			threads = addThread(threads, visited, next, recorded, pos)
+		}
+		return threads
 	}
 
-	// var leadsToSink bool
-	var mergedStateValue T
+	initialSlots := make([]int, max(slots, 2))
+	for i := range initialSlots {
+		initialSlots[i] = -1
+	}
+	threads := addThread(nil, set.NewThreadUnsafeSet[T](), nfa.IntialState, initialSlots, start)
+	for pos := start; pos < end && len(threads) > 0; pos++ {
+		var nextThreads []thread
+		visited := set.NewThreadUnsafeSet[T]()
+		for _, t := range threads {
+			for _, next := range nfa.next(t.state, input[pos]) {
+				nextThreads = addThread(nextThreads, visited, next, t.slots, pos+1)
+			}
+		}
+		threads = nextThreads
+	}
 
-	// use queue for keeping track of subsets of states
-	toProcess := queue.NewQueue(sliceISWC)
+	for _, t := range threads {
+		if nfa.FinalStates.Contains(t.state) {
+			recorded := slices.Clone(t.slots)
+			recorded[0], recorded[1] = start, end
+			return recorded
+		}
+	}
+	return nil
+}
 
-	for toProcess.Size() > 0 {
-		currentStateSlice, _ := toProcess.Dequeue()
-		slices.Sort(currentStateSlice)
-		var originState T
-		if state, ok := mergeStates[fmt.Sprint(currentStateSlice)]; ok {
-			originState = state
-		} else {
-			originState = g.Generate()
-			mergeStates[fmt.Sprint(currentStateSlice)] = originState
-		}
-		if dfaDelta[originState] == nil {
-			dfaDelta[originState] = make(map[Symbol]T)
-		}
-		// For each symbol, for each state, we need to analyze all paths and build states accordingly
-		for symbol := range nfa.Alphabet.Iter() {
-			// build eps closures for all transitions
-			allTransitionsWithEps := set.NewSet[T]()
-			for _, state := range currentStateSlice {
-				transitions, okT := nfa.Delta[state]
-				if !okT {
-					continue
-				}
+// implemented using the subset construction algorithm. Assertions are resolved while building the
+// DFA: each DFA state also remembers the kind of symbol read last, and the assertions of its NFA
+// states are checked against the next symbol when leaving it, or against the end of the input when
+// deciding whether it is final
+func (nfa *NFA[T]) ToDFA(g generator.Generator[T]) *DFA[T] {
+	return nfa.determinize(g, false)
+}
 
-				symTransitions, okTS := transitions[symbol]
-				if !okTS {
-					continue
-				}
+// ToSearchDFA builds a DFA which reports matches one symbol late, so that the assertions at the end
+// of a match can look at the symbol after it. The DFA first reads the symbol before the input, or
+// TextBoundary at the start of the input, then the input itself, and finally TextBoundary. It is
+// in a final state right after reading the symbol that follows a match
+func (nfa *NFA[T]) ToSearchDFA(g generator.Generator[T]) *DFA[T] {
+	return nfa.determinize(g, true)
+}
 
-				for _, st := range symTransitions {
-					allTransitionsWithEps.Append(epsClosures[st].ToSlice()...)
-				}
+func (nfa *NFA[T]) determinize(g generator.Generator[T], delayed bool) *DFA[T] {
+	d := newDeterminizer(nfa, delayed)
+	type pending struct {
+		subset[T]
+		state T
+	}
 
-				stateSlice := allTransitionsWithEps.ToSlice()
-				slices.Sort(stateSlice)
+	// use a trie for generating DFA states for sets of NFA states
+	mergeStates := make(map[string]T)
+	dfaAllStates := set.NewSet[T]()
+	dfaFinalStates := set.NewSet[T]()
+	dfaDelta := make(map[T][]Transition[T])
 
-				// if the set of states has already been processed - don't requeue it
-				var ok bool
-				if mergedStateValue, ok = mergeStates[fmt.Sprint(stateSlice)]; !ok {
-					mergedStateValue = g.Generate()
-					mergeStates[fmt.Sprint(stateSlice)] = mergedStateValue
-					toProcess.Enqueue(stateSlice)
-				}
+	// use queue for keeping track of subsets of states
+	toProcess := queue.NewQueue[pending]()
+	dfaState := func(s subset[T]) T {
+		key := d.key(s)
+		if state, ok := mergeStates[key]; ok {
+			return state
+		}
+		state := g.Generate()
+		mergeStates[key] = state
+		dfaAllStates.Add(state)
+		toProcess.Enqueue(pending{subset: s, state: state})
+		return state
+	}
 
-				// add newly generated state to all states
-				dfaAllStates.Add(mergedStateValue)
+	var dfaInitialState T
+	if delayed {
+		// the initial state reads the symbol before the input, which may be any symbol at all
+		dfaInitialState = g.Generate()
+		dfaAllStates.Add(dfaInitialState)
+		edges := []Transition[T]{{Label: SymbolSet(TextBoundary), Next: dfaState(d.start(TextBoundary))}}
+		for class, symbol := range d.symbols {
+			edges = append(edges, Transition[T]{Label: d.p.minterms[class], Next: dfaState(d.start(symbol))})
+		}
+		dfaDelta[dfaInitialState] = mergeTransitions(edges)
+	} else {
+		dfaInitialState = dfaState(d.start(TextBoundary))
+	}
 
-				// add to final states if the set contains any final state
-				if nfa.FinalStates.ContainsAny(stateSlice...) {
-					dfaFinalStates.Add(mergedStateValue)
-				}
+	for toProcess.Size() > 0 {
+		current, _ := toProcess.Dequeue()
+		if d.final(current.subset) {
+			dfaFinalStates.Add(current.state)
+		}
 
-				if _, ok := dfaDelta[mergedStateValue]; !ok {
-					dfaDelta[mergedStateValue] = make(map[Symbol]T)
-				}
-				// create transition from origin to newly generated state
-				dfaDelta[originState][symbol] = mergedStateValue
+		// For each minterm, for each state, we need to analyze all paths and build states
+		// accordingly. The assertions only take a few distinct values, whatever the next symbol, so
+		// the states they resolve to are shared by the minterms
+		resolved := make(map[Assertion]*resolution[T])
+		var edges []Transition[T]
+		for class := range d.textClass + 1 {
+			if next, ok := d.next(current.subset, class, resolved); ok {
+				// create transition from origin to the state of the subset
+				edges = append(edges, Transition[T]{Label: d.label(class), Next: dfaState(next)})
 			}
 		}
+		dfaDelta[current.state] = mergeTransitions(edges)
 	}
 
-	dfa := &DFA[T]{
+	return &DFA[T]{
 		InitialState: dfaInitialState,
 		FinalStates:  dfaFinalStates,
 		AllStates:    dfaAllStates,
 		Delta:        dfaDelta,
-		Alphabet:     nfa.Alphabet,
 	}
+}
+
+// subset is a state of the DFA of an NFA: a set of NFA states, the kind of symbol read last and,
+// for delayed DFAs, whether a match ended right before it
+type subset[T StateLike] struct {
+	states  []T
+	before  Symbol
+	matched bool
+}
 
-	return dfa
+// resolution holds the states that the assertions of a subset resolve to for some symbol after it,
+// and the states reached from those on each minterm
+type resolution[T StateLike] struct {
+	states []T
+	moved  [][]T
+}
+
+// determinizer computes the subsets of the subset construction and the transitions between them,
+// for both the DFAs built at once and the lazy DFAs built while searching
+type determinizer[T StateLike] struct {
+	nfa           *NFA[T]
+	delayed       bool
+	hasAssertions bool
+
+	// the symbols are split into minterms, which neither the transitions nor the assertions tell
+	// apart, so the DFA only follows the transitions of the NFA on one symbol of each minterm.
+	// TextBoundary comes after the minterms, as textClass
+	p         *partition
+	symbols   []Symbol
+	textClass int
+	// classes holds the minterms of the label of each transition of each NFA state
+	classes map[T][][]int
+
+	// sets of NFA states are keyed by the numbers of their states, which is much cheaper than
+	// printing them
+	numbers map[T]int
+	buf     []byte
+	// many minterms lead to the same set of states, so its closure is only computed once
+	closures map[string][]T
+}
+
+func newDeterminizer[T StateLike](nfa *NFA[T], delayed bool) *determinizer[T] {
+	var assertions Assertion
+	for _, a := range nfa.Assertions {
+		assertions |= a
+	}
+
+	// all the code points are covered, as delayed DFAs also report matches before symbols without
+	// any transition
+	labels := append([]IntervalSet{Unicode}, assertionLabels(assertions)...)
+	for _, transitions := range nfa.Delta {
+		for _, t := range transitions {
+			labels = append(labels, t.Label)
+		}
+	}
+	p := newPartition(labels)
+	symbols := make([]Symbol, len(p.minterms))
+	for class, minterm := range p.minterms {
+		symbols[class] = minterm[0].Lo
+	}
+	classes := make(map[T][][]int, len(nfa.Delta))
+	for state, transitions := range nfa.Delta {
+		for _, t := range transitions {
+			classes[state] = append(classes[state], p.classesOf(t.Label))
+		}
+	}
+
+	return &determinizer[T]{
+		nfa:           nfa,
+		delayed:       delayed,
+		hasAssertions: len(nfa.Assertions) > 0,
+		p:             p,
+		symbols:       symbols,
+		textClass:     len(symbols),
+		classes:       classes,
+		numbers:       make(map[T]int, nfa.AllStates.Cardinality()),
+		closures:      make(map[string][]T),
+	}
+}
+
+func (d *determinizer[T]) appendKey(key []byte, states []T) []byte {
+	for _, state := range states {
+		number, ok := d.numbers[state]
+		if !ok {
+			number = len(d.numbers)
+			d.numbers[state] = number
+		}
+		key = strconv.AppendInt(key, int64(number), 10)
+		key = append(key, ',')
+	}
+	return key
+}
+
+// key returns a key which is the same for the subsets that make the same DFA state
+func (d *determinizer[T]) key(s subset[T]) string {
+	if !d.hasAssertions || len(s.states) == 0 {
+		// the symbol read last only matters to assertions
+		s.before = otherSymbol
+	}
+	d.buf = d.appendKey(d.buf[:0], s.states)
+	d.buf = strconv.AppendInt(d.buf, int64(s.before), 10)
+	d.buf = strconv.AppendBool(d.buf, s.matched)
+	return string(d.buf)
+}
+
+func (d *determinizer[T]) closure(moved []T) []T {
+	slices.Sort(moved)
+	moved = slices.Compact(moved)
+	d.buf = d.appendKey(d.buf[:0], moved)
+	closed, ok := d.closures[string(d.buf)]
+	if !ok {
+		closed = d.nfa.closure(moved, 0)
+		d.closures[string(d.buf)] = closed
+	}
+	return closed
+}
+
+// start returns the subset that the DFA starts from, after the symbol before the input
+func (d *determinizer[T]) start(before Symbol) subset[T] {
+	return subset[T]{states: d.closure([]T{d.nfa.IntialState}), before: lookbehind(before)}
+}
+
+// final reports whether a subset is a final state of the DFA. The assertions of its NFA states are
+// checked against the end of the input, except for delayed DFAs which report matches one symbol
+// late
+func (d *determinizer[T]) final(s subset[T]) bool {
+	if d.delayed {
+		return s.matched
+	}
+	return d.nfa.FinalStates.ContainsAny(d.nfa.closure(s.states, AssertionsBetween(s.before, TextBoundary))...)
+}
+
+// label returns the symbols of a class
+func (d *determinizer[T]) label(class int) IntervalSet {
+	if class == d.textClass {
+		return SymbolSet(TextBoundary)
+	}
+	return d.p.minterms[class]
+}
+
+// next returns the subset reached from a subset on the symbols of a class, and whether there is a
+// transition at all. Delayed DFAs also report matches before the symbols without any transition,
+// and before TextBoundary, after which no symbol can be read. The resolutions of the assertions
+// of the subset are kept in resolved, if it is not nil
+func (d *determinizer[T]) next(s subset[T], class int, resolved map[Assertion]*resolution[T]) (subset[T], bool) {
+	if class == d.textClass {
+		atEnd := d.nfa.closure(s.states, AssertionsBetween(s.before, TextBoundary))
+		return subset[T]{matched: true}, d.delayed && d.nfa.FinalStates.ContainsAny(atEnd...)
+	}
+
+	symbol := d.symbols[class]
+	holds := AssertionsBetween(s.before, symbol)
+	r, ok := resolved[holds]
+	if !ok {
+		r = &resolution[T]{states: s.states, moved: make([][]T, len(d.symbols))}
+		if d.hasAssertions {
+			r.states = d.nfa.closure(s.states, holds)
+		}
+		for _, state := range r.states {
+			for i, t := range d.nfa.Delta[state] {
+				for _, class := range d.classes[state][i] {
+					r.moved[class] = append(r.moved[class], t.Next)
+				}
+			}
+		}
+		if resolved != nil {
+			resolved[holds] = r
+		}
+	}
+	next := subset[T]{
+		states:  d.closure(r.moved[class]),
+		before:  lookbehind(symbol),
+		matched: d.delayed && d.nfa.FinalStates.ContainsAny(r.states...),
+	}
+	return next, len(next.states) > 0 || next.matched
 }
@@ -0,0 +1,227 @@
+package automata
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/bogdan-deac/regex/common/generator"
+	set "github.com/deckarep/golang-set/v2"
+	queue "github.com/oleiade/lane/v2"
+)
+
+// Complete returns a total DFA over an alphabet: every state has a transition on each symbol of the
+// alphabet, the symbols which the DFA rejects leading to an explicit dead state that loops on the
+// whole alphabet. The labels are cut down to the alphabet, so the symbols outside of it stay
+// rejected. The dead state is the first state drawn from g which is not already a state of the DFA
+func (dfa *DFA[T]) Complete(alphabet IntervalSet, g generator.Generator[T]) *DFA[T] {
+	dead := g.Generate()
+	for dfa.AllStates.Contains(dead) {
+		dead = g.Generate()
+	}
+	allStates := dfa.AllStates.Clone()
+	delta := make(map[T][]Transition[T], len(dfa.Delta)+1)
+	for state := range dfa.AllStates.Iter() {
+		missing := alphabet
+		var transitions []Transition[T]
+		for _, t := range dfa.Delta[state] {
+			label := t.Label.Intersect(alphabet)
+			missing = missing.Difference(label)
+			transitions = append(transitions, Transition[T]{Label: label, Next: t.Next})
+		}
+		if !missing.IsEmpty() {
+			transitions = append(transitions, Transition[T]{Label: missing, Next: dead})
+			allStates.Add(dead)
+		}
+		delta[state] = mergeTransitions(transitions)
+	}
+	if allStates.Contains(dead) {
+		delta[dead] = []Transition[T]{{Label: alphabet, Next: dead}}
+	}
+
+	// the transitions on symbols outside of the alphabet may have been the only way into some states
+	completed := &DFA[T]{
+		InitialState: dfa.InitialState,
+		FinalStates:  dfa.FinalStates.Clone(),
+		AllStates:    allStates,
+		Delta:        delta,
+	}
+	return completed.RemoveUnreachableStates()
+}
+
+// Complement returns a DFA which accepts exactly the inputs that the DFA rejects. The symbols
+// without any transition are rejected by both, so the DFA should first be completed over the
+// alphabet that the complement is taken in, see Complete
+func (dfa *DFA[T]) Complement() *DFA[T] {
+	return &DFA[T]{
+		InitialState: dfa.InitialState,
+		FinalStates:  dfa.AllStates.Difference(dfa.FinalStates),
+		AllStates:    dfa.AllStates.Clone(),
+		Delta:        maps.Clone(dfa.Delta),
+	}
+}
+
+// Intersect returns a minimal DFA which accepts the inputs accepted by both DFAs
+func (dfa *DFA[T]) Intersect(other *DFA[T], g generator.Generator[T]) *DFA[T] {
+	return dfa.product(other, g, func(a, b bool) bool { return a && b })
+}
+
+// Union returns a minimal DFA which accepts the inputs accepted by either DFA
+func (dfa *DFA[T]) Union(other *DFA[T], g generator.Generator[T]) *DFA[T] {
+	return dfa.product(other, g, func(a, b bool) bool { return a || b })
+}
+
+// Difference returns a minimal DFA which accepts the inputs accepted by dfa but not by other
+func (dfa *DFA[T]) Difference(other *DFA[T], g generator.Generator[T]) *DFA[T] {
+	return dfa.product(other, g, func(a, b bool) bool { return a && !b })
+}
+
+// SymmetricDifference returns a minimal DFA which accepts the inputs accepted by exactly one of the
+// DFAs
+func (dfa *DFA[T]) SymmetricDifference(other *DFA[T], g generator.Generator[T]) *DFA[T] {
+	return dfa.product(other, g, func(a, b bool) bool { return a != b })
+}
+
+// pair is a state of a product of DFAs, made of a state of each of them. A DFA without a state has
+// already rejected the input
+type pair[T StateLike] struct {
+	a, b       T
+	hasA, hasB bool
+}
+
+// alive reports whether a pair may still lead to an input for which accepts holds. Once a DFA has
+// rejected the input, the pair is only worth following if the other DFA alone may lead to one
+func (p pair[T]) alive(accepts func(a, b bool) bool) bool {
+	switch {
+	case p.hasA && p.hasB:
+		return true
+	case p.hasA:
+		return accepts(true, false) || accepts(false, false)
+	case p.hasB:
+		return accepts(false, true) || accepts(false, false)
+	}
+	return false
+}
+
+// step returns the pair reached from a pair by reading a symbol
+func (p pair[T]) step(a, b *DFA[T], sym Symbol) pair[T] {
+	var next pair[T]
+	if p.hasA {
+		next.a, next.hasA = a.Step(p.a, sym)
+	}
+	if p.hasB {
+		next.b, next.hasB = b.Step(p.b, sym)
+	}
+	return next
+}
+
+// productMinterms returns the minterms of the labels of both DFAs
+func productMinterms[T StateLike](a, b *DFA[T]) []IntervalSet {
+	var labels []IntervalSet
+	for _, d := range []*DFA[T]{a, b} {
+		for _, transitions := range d.Delta {
+			for _, t := range transitions {
+				labels = append(labels, t.Label)
+			}
+		}
+	}
+	return newPartition(labels).minterms
+}
+
+// product runs both DFAs side by side, on one symbol of each minterm of their labels, and accepts
+// the inputs for which accepts holds of whether each DFA accepts them. The states of the result are
+// drawn from g, and are unrelated to the states of the DFAs
+func (dfa *DFA[T]) product(other *DFA[T], g generator.Generator[T], accepts func(a, b bool) bool) *DFA[T] {
+	minterms := productMinterms(dfa, other)
+	productStates := make(map[pair[T]]T)
+	allStates := set.NewSet[T]()
+	finalStates := set.NewSet[T]()
+	delta := make(map[T][]Transition[T])
+	toProcess := queue.NewQueue[pair[T]]()
+	productState := func(p pair[T]) T {
+		if state, ok := productStates[p]; ok {
+			return state
+		}
+		state := g.Generate()
+		productStates[p] = state
+		allStates.Add(state)
+		toProcess.Enqueue(p)
+		return state
+	}
+
+	initialState := productState(pair[T]{a: dfa.InitialState, b: other.InitialState, hasA: true, hasB: true})
+	for toProcess.Size() > 0 {
+		current, _ := toProcess.Dequeue()
+		state := productStates[current]
+		if accepts(current.hasA && dfa.FinalStates.Contains(current.a), current.hasB && other.FinalStates.Contains(current.b)) {
+			finalStates.Add(state)
+		}
+
+		var transitions []Transition[T]
+		for _, minterm := range minterms {
+			if next := current.step(dfa, other, minterm[0].Lo); next.alive(accepts) {
+				transitions = append(transitions, Transition[T]{Label: minterm, Next: productState(next)})
+			}
+		}
+		delta[state] = mergeTransitions(transitions)
+	}
+	return NewDFA(initialState, finalStates, allStates, delta).Minimize()
+}
+
+// Equivalent reports whether both DFAs accept the same inputs. If they do not, it also returns one
+// of the shortest inputs accepted by only one of them
+func Equivalent[T StateLike](a, b *DFA[T]) ([]Symbol, bool) {
+	return witness(a, b, func(a, b bool) bool { return a != b })
+}
+
+// Subset reports whether b accepts all the inputs that a accepts. If it does not, it also returns
+// one of the shortest inputs accepted by a but not by b
+func Subset[T StateLike](a, b *DFA[T]) ([]Symbol, bool) {
+	return witness(a, b, func(a, b bool) bool { return a && !b })
+}
+
+// witness runs a breadth-first search of the product of the DFAs for a pair for which differs
+// holds. The search finds the pairs in the order of the length of the shortest inputs which lead
+// to them, so the input of the first such pair is one of the shortest witnesses
+func witness[T StateLike](a, b *DFA[T], differs func(a, b bool) bool) ([]Symbol, bool) {
+	minterms := productMinterms(a, b)
+
+	// each pair remembers the pair it was first reached from, and the symbol read on the way
+	type parent struct {
+		from   pair[T]
+		symbol Symbol
+	}
+	initial := pair[T]{a: a.InitialState, b: b.InitialState, hasA: true, hasB: true}
+	parents := map[pair[T]]parent{initial: {}}
+	toProcess := queue.NewQueue(initial)
+	for toProcess.Size() > 0 {
+		current, _ := toProcess.Dequeue()
+		if differs(current.hasA && a.FinalStates.Contains(current.a), current.hasB && b.FinalStates.Contains(current.b)) {
+			var input []Symbol
+			for p := current; p != initial; p = parents[p].from {
+				input = append(input, parents[p].symbol)
+			}
+			slices.Reverse(input)
+			return input, false
+		}
+
+		for _, minterm := range minterms {
+			symbol := readable(minterm)
+			next := current.step(a, b, symbol)
+			if _, seen := parents[next]; seen || !next.alive(differs) {
+				continue
+			}
+			parents[next] = parent{from: current, symbol: symbol}
+			toProcess.Enqueue(next)
+		}
+	}
+	return nil, true
+}
+
+// readable returns a symbol of a minterm, preferably a visible ASCII character so that witnesses
+// are easy to read
+func readable(minterm IntervalSet) Symbol {
+	if visible := minterm.Intersect(IntervalSet{{'!', '~'}}); !visible.IsEmpty() {
+		return visible[0].Lo
+	}
+	return minterm[0].Lo
+}
@@ -0,0 +1,220 @@
+package automata
+
+import (
+	"slices"
+
+	"github.com/bogdan-deac/regex/common/generator"
+)
+
+// Searcher finds leftmost-longest matches of a language inside larger inputs. The reversed language,
+// preceded by a loop on any symbol, is run backwards over the input to find every position where a
+// match starts. The language itself is then run forwards from the leftmost start to find where the
+// longest match ends. Both automata are delayed DFAs, as built by ToSearchDFA, so that the
+// assertions at both ends of a match can look at the symbols around it, and they are built lazily,
+// while searching
+//
+// The state of the reverse automaton at a position tells which NFA states can still lead to the end
+// of a match from there, so the forward pass stops as soon as it cannot reach any of them and both
+// passes are linear in the size of the input. The submatches of capture groups are extracted by
+// simulating the NFA on the span of each match
+type Searcher[T StateLike] struct {
+	NFA *NFA[T]
+	// GroupNames holds the name of each capture group, indexed by group number. Group 0 is the
+	// whole match
+	GroupNames []string
+
+	forward *lazyDFA[T]
+	reverse *lazyDFA[T]
+}
+
+func NewSearcher[T StateLike](nfa *NFA[T], groupNames []string, g generator.Generator[T]) *Searcher[T] {
+	reverse := nfa.Reverse(g).Unanchored(g)
+	// both automata number the NFA states the same way, so that the states they stand for at a
+	// position of the input can be compared
+	states := reverse.AllStates.ToSlice()
+	slices.Sort(states)
+	numbers := make(map[T]int, len(states))
+	for i, state := range states {
+		numbers[state] = i
+	}
+	return &Searcher[T]{
+		NFA:        nfa,
+		GroupNames: groupNames,
+		forward:    newLazyDFA(nfa, numbers),
+		reverse:    newLazyDFA(reverse, numbers),
+	}
+}
+
+// Match is a match of a pattern in an input, along with the submatches of its capture groups
+type Match struct {
+	Input []Symbol
+	// Offsets holds the start and end offsets of the whole match, followed by a pair of offsets for
+	// each capture group. Groups that did not take part in the match have -1 offsets
+	Offsets []int
+	// GroupNames holds the name of each capture group, indexed by group number
+	GroupNames []string
+}
+
+// Group returns the submatch of a capture group, or nil if the group did not take part in the
+// match. Group 0 is the whole match
+func (m *Match) Group(i int) []Symbol {
+	if i < 0 || 2*i+1 >= len(m.Offsets) || m.Offsets[2*i] < 0 {
+		return nil
+	}
+	return m.Input[m.Offsets[2*i]:m.Offsets[2*i+1]]
+}
+
+// NamedGroup returns the submatch of the capture group with the given name, or nil if there is no
+// such group or if it did not take part in the match
+func (m *Match) NamedGroup(name string) []Symbol {
+	if name == "" {
+		return nil
+	}
+	for i, groupName := range m.GroupNames {
+		if groupName == name {
+			return m.Group(i)
+		}
+	}
+	return nil
+}
+
+// FindMatch returns the leftmost-longest match in the input along with its submatches, or nil if
+// there is none
+func (s *Searcher[T]) FindMatch(input []Symbol) *Match {
+	matches := s.FindAllMatches(input, 1)
+	if matches == nil {
+		return nil
+	}
+	return matches[0]
+}
+
+// FindAllMatches returns at most n successive non-overlapping matches in the input, along with
+// their submatches. If n is negative, all the matches are returned
+func (s *Searcher[T]) FindAllMatches(input []Symbol, n int) []*Match {
+	var matches []*Match
+	for _, loc := range s.FindAllIndex(input, n) {
+		matches = append(matches, &Match{
+			Input:      input,
+			Offsets:    s.NFA.Submatches(input, loc[0], loc[1], 2*max(len(s.GroupNames), 1)),
+			GroupNames: s.GroupNames,
+		})
+	}
+	return matches
+}
+
+// Find returns the leftmost-longest match in the input, or nil if there is none
+func (s *Searcher[T]) Find(input []Symbol) []Symbol {
+	loc := s.FindIndex(input)
+	if loc == nil {
+		return nil
+	}
+	return input[loc[0]:loc[1]]
+}
+
+// FindIndex returns the start and end offsets of the leftmost-longest match in the input, or nil
+// if there is none
+func (s *Searcher[T]) FindIndex(input []Symbol) []int {
+	matches := s.FindAllIndex(input, 1)
+	if matches == nil {
+		return nil
+	}
+	return matches[0]
+}
+
+// FindAll returns at most n successive non-overlapping matches in the input. If n is negative, all
+// the matches are returned
+func (s *Searcher[T]) FindAll(input []Symbol, n int) [][]Symbol {
+	var matches [][]Symbol
+	for _, loc := range s.FindAllIndex(input, n) {
+		matches = append(matches, input[loc[0]:loc[1]])
+	}
+	return matches
+}
+
+// FindAllIndex returns the offsets of at most n successive non-overlapping matches in the input. If
+// n is negative, all the matches are returned. Empty matches right after a previous match are
+// ignored
+func (s *Searcher[T]) FindAllIndex(input []Symbol, n int) [][]int {
+	starts, reverseStates := s.matchStarts(input)
+
+	var matches [][]int
+	previousEnd := -1
+	for start := 0; start <= len(input) && (n < 0 || len(matches) < n); start++ {
+		if !starts[start] {
+			continue
+		}
+		end := s.longestMatch(input, start, reverseStates)
+		if end < 0 || end == start && start == previousEnd {
+			continue
+		}
+		matches = append(matches, []int{start, end})
+		previousEnd = end
+		if end > start {
+			// the loop moves on to the end of the match
+			start = end - 1
+		}
+	}
+	return matches
+}
+
+// matchStarts runs the reverse automaton backwards over the input. Since it reports matches one
+// symbol late, a match starts at offset i exactly when it is in a final state after reading the
+// symbol before i. It also returns the state of the automaton at each offset, before reading the
+// symbol before it, or nil for the states that the automaton does not keep
+func (s *Searcher[T]) matchStarts(input []Symbol) ([]bool, []*lazyState[T]) {
+	starts := make([]bool, len(input)+1)
+	states := make([]*lazyState[T], len(input)+1)
+	reverse := s.reverse
+	currentState := reverse.start(TextBoundary)
+	for i := len(input); i >= 0; i-- {
+		if currentState.cached() {
+			states[i] = currentState
+		}
+		symbol := Symbol(TextBoundary)
+		if i > 0 {
+			symbol = input[i-1]
+		}
+		nextState := reverse.step(currentState, symbol)
+		if nextState == reverse.dead {
+			// only the loop at the start survives, and it survives any symbol
+			currentState = reverse.start(symbol)
+			continue
+		}
+		currentState = nextState
+		starts[i] = currentState.final
+	}
+	return starts, states
+}
+
+// longestMatch returns the end of the longest match starting at the given offset, or -1 if there
+// is none. The states of the reverse automaton at each offset bound the search
+func (s *Searcher[T]) longestMatch(input []Symbol, start int, reverseStates []*lazyState[T]) int {
+	before := Symbol(TextBoundary)
+	if start > 0 {
+		before = input[start-1]
+	}
+	forward := s.forward
+	currentState := forward.start(before)
+
+	end := -1
+	for i := start; i <= len(input); i++ {
+		symbol := Symbol(TextBoundary)
+		if i < len(input) {
+			symbol = input[i]
+		}
+		// a match which ends at i or later goes through one of the NFA states that the reverse
+		// automaton stands for at i
+		if r := reverseStates[i]; r != nil && !overlap(forward.around(currentState, symbol), s.reverse.around(r, before)) {
+			break
+		}
+		if currentState = forward.step(currentState, symbol); currentState == forward.dead {
+			break
+		}
+		// the forward automaton reports matches one symbol late
+		if currentState.final {
+			end = i
+		}
+		before = symbol
+	}
+	return end
+}
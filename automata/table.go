@@ -0,0 +1,104 @@
+package automata
+
+import "slices"
+
+// DeadState is the number of the state of a Table which rejects all inputs. The symbols without a
+// transition lead to it, and it loops on every symbol
+const DeadState uint32 = 0
+
+// Table is the dense transition table of a DFA, for running it on inputs quickly. The symbols are
+// mapped to classes of symbols which no transition tells apart, and the states to consecutive
+// numbers, so that each step is a single lookup in Transitions
+type Table[T StateLike] struct {
+	// Transitions holds the number of the state reached from each state on each class of symbols,
+	// at state*NumClasses+class
+	Transitions []uint32
+	// NumClasses is the number of classes of symbols. Class 0 holds the symbols without a
+	// transition out of any state
+	NumClasses int
+	Initial    uint32
+	// Final tells whether each state is final
+	Final []bool
+	// States holds the DFA state of each number. Number 0 is the DeadState, which is not a state of
+	// the DFA
+	States []T
+
+	// the classes of the ASCII symbols are looked up directly, and the others by a binary search for
+	// the elementary interval of the partition that they belong to
+	ascii   [128]uint32
+	starts  []Symbol
+	classes []uint32
+}
+
+func newTable[T StateLike](dfa *DFA[T]) *Table[T] {
+	p := dfa.partition()
+	states := dfa.AllStates.ToSlice()
+	slices.Sort(states)
+	numbers := make(map[T]uint32, len(states))
+	for i, state := range states {
+		numbers[state] = uint32(i + 1)
+	}
+
+	var dead T
+	table := &Table[T]{
+		NumClasses:  len(p.minterms) + 1,
+		Initial:     numbers[dfa.InitialState],
+		Final:       make([]bool, len(states)+1),
+		States:      append([]T{dead}, states...),
+		starts:      p.starts,
+		classes:     make([]uint32, len(p.classes)),
+		Transitions: make([]uint32, (len(states)+1)*(len(p.minterms)+1)),
+	}
+	for e, class := range p.classes {
+		table.classes[e] = uint32(class + 1)
+	}
+	for sym := range table.ascii {
+		table.ascii[sym] = table.lookup(Symbol(sym))
+	}
+
+	for i, state := range states {
+		number := i + 1
+		table.Final[number] = dfa.FinalStates.Contains(state)
+		for _, t := range dfa.Delta[state] {
+			for _, class := range p.classesOf(t.Label) {
+				table.Transitions[number*table.NumClasses+class+1] = numbers[t.Next]
+			}
+		}
+	}
+	return table
+}
+
+// Class returns the class of a symbol
+func (t *Table[T]) Class(sym Symbol) uint32 {
+	if sym >= 0 && int(sym) < len(t.ascii) {
+		return t.ascii[sym]
+	}
+	return t.lookup(sym)
+}
+
+func (t *Table[T]) lookup(sym Symbol) uint32 {
+	e, found := slices.BinarySearch(t.starts, sym)
+	if !found {
+		e--
+	}
+	if e < 0 {
+		return 0
+	}
+	return t.classes[e]
+}
+
+// Step returns the number of the state reached from a state by reading a symbol
+func (t *Table[T]) Step(state uint32, sym Symbol) uint32 {
+	return t.Transitions[int(state)*t.NumClasses+int(t.Class(sym))]
+}
+
+// Accepts reports whether the DFA accepts the input
+func (t *Table[T]) Accepts(input []Symbol) bool {
+	state := t.Initial
+	for _, sym := range input {
+		if state = t.Step(state, sym); state == DeadState {
+			return false
+		}
+	}
+	return t.Final[state]
+}
@@ -0,0 +1,164 @@
+// Package build assembles regexes from code, as an alternative to writing patterns that need
+// escaping. The regexes it builds compile with regex.CompileAST:
+//
+//	url := build.Seq(build.Lit("http"), build.Opt(build.Lit("s")), build.Lit("://"), build.Plus(build.Class('a', 'z')))
+//	re, err := regex.CompileAST(url)
+package build
+
+import (
+	"github.com/bogdan-deac/regex/ast"
+	"github.com/bogdan-deac/regex/automata"
+	"github.com/bogdan-deac/regex/common/generator"
+)
+
+type Regex = ast.Regex[generator.PrintableInt]
+
+// Unbounded is the maximum count of a Repeat without an upper bound
+const Unbounded = ast.Unbounded
+
+// Lit matches a text literally
+func Lit(text string) Regex {
+	var chars []Regex
+	for _, c := range text {
+		chars = append(chars, ast.Char[generator.PrintableInt]{Value: c})
+	}
+	return Seq(chars...)
+}
+
+// Seq matches its parts one after the other. Without parts, it matches the empty string
+func Seq(parts ...Regex) Regex {
+	if len(parts) == 0 {
+		return Empty()
+	}
+	regex := parts[0]
+	for _, part := range parts[1:] {
+		regex = appendCat(regex, part)
+	}
+	return regex
+}
+
+// appendCat appends a regex to a concatenation. Concatenations nest to the left, as in parsed
+// regexes, so the concatenations which are appended are spliced
+func appendCat(regex, part Regex) Regex {
+	if cat, ok := part.(ast.Cat[generator.PrintableInt]); ok {
+		return appendCat(appendCat(regex, cat.Left), cat.Right)
+	}
+	return ast.Cat[generator.PrintableInt]{Left: regex, Right: part}
+}
+
+// Alt matches any of its alternatives, preferring the first ones when extracting submatches.
+// Without alternatives, it matches nothing
+func Alt(alternatives ...Regex) Regex {
+	if len(alternatives) == 0 {
+		return Nothing()
+	}
+	regex := alternatives[0]
+	for _, alternative := range alternatives[1:] {
+		regex = ast.Or[generator.PrintableInt]{Branches: []Regex{regex, alternative}}
+	}
+	return regex
+}
+
+// Opt matches a regex or the empty string, like re?
+func Opt(re Regex) Regex {
+	return ast.Maybe[generator.PrintableInt]{Subexp: re}
+}
+
+// Star matches any number of repetitions of a regex, like re*
+func Star(re Regex) Regex {
+	return ast.Star[generator.PrintableInt]{Subexp: re}
+}
+
+// Plus matches one or more repetitions of a regex, like re+
+func Plus(re Regex) Regex {
+	return ast.Plus[generator.PrintableInt]{Subexp: re}
+}
+
+// Repeat matches between min and max repetitions of a regex, like re{min,max}. Max is Unbounded
+// for re{min,}
+func Repeat(re Regex, min, max int) Regex {
+	return ast.Repeat[generator.PrintableInt]{Subexp: re, Min: min, Max: max}
+}
+
+// Lazy makes a quantifier prefer fewer repetitions when extracting submatches, like re*?. Other
+// regexes are returned as they are
+func Lazy(re Regex) Regex {
+	switch r := re.(type) {
+	case ast.Star[generator.PrintableInt]:
+		r.Lazy = true
+		return r
+	case ast.Plus[generator.PrintableInt]:
+		r.Lazy = true
+		return r
+	case ast.Maybe[generator.PrintableInt]:
+		r.Lazy = true
+		return r
+	case ast.Repeat[generator.PrintableInt]:
+		r.Lazy = true
+		return r
+	}
+	return re
+}
+
+// Class matches a single character between lo and hi, both included, like [lo-hi]
+func Class(lo, hi rune) Regex {
+	return ast.ClassRegex[generator.PrintableInt](ast.NewClass(ast.CharRange{Lo: lo, Hi: hi}))
+}
+
+// AnyOf matches a single character of chars
+func AnyOf(chars string) Regex {
+	return ast.ClassRegex[generator.PrintableInt](charsClass(chars))
+}
+
+// NoneOf matches a single character which is not in chars
+func NoneOf(chars string) Regex {
+	return ast.ClassRegex[generator.PrintableInt](charsClass(chars).Negate())
+}
+
+func charsClass(chars string) ast.Class {
+	var class ast.Class
+	for _, c := range chars {
+		class = class.Union(ast.NewClass(ast.CharRange{Lo: c, Hi: c}))
+	}
+	return class
+}
+
+// Any matches any single character, newlines included
+func Any() Regex {
+	return ast.Wildcard[generator.PrintableInt]{}
+}
+
+// Group captures the text matched by a regex. Groups are numbered by regex.CompileAST
+func Group(re Regex) Regex {
+	return ast.Capture[generator.PrintableInt]{Subexp: re}
+}
+
+// Named captures the text matched by a regex in a named group
+func Named(name string, re Regex) Regex {
+	return ast.Capture[generator.PrintableInt]{Subexp: re, Name: name}
+}
+
+// Begin matches at the start of the input, like \A
+func Begin() Regex {
+	return ast.Assertion[generator.PrintableInt]{Kind: automata.BeginText}
+}
+
+// End matches at the end of the input, like \z
+func End() Regex {
+	return ast.Assertion[generator.PrintableInt]{Kind: automata.EndText}
+}
+
+// WordBoundary matches between a word character and a non-word character, like \b
+func WordBoundary() Regex {
+	return ast.Assertion[generator.PrintableInt]{Kind: automata.WordBoundary}
+}
+
+// Empty matches the empty string
+func Empty() Regex {
+	return ast.Empty[generator.PrintableInt]{}
+}
+
+// Nothing matches no string at all
+func Nothing() Regex {
+	return ast.Nothing[generator.PrintableInt]{}
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrorKind classifies syntax errors
+type ErrorKind uint8
+
+const (
+	// UnbalancedParen is an unclosed group or a closing parenthesis without a group
+	UnbalancedParen ErrorKind = iota
+	// UnbalancedBracket is an unclosed set or a closing square bracket without a set
+	UnbalancedBracket
+	// DanglingQuantifier is a quantifier with nothing to repeat
+	DanglingQuantifier
+	// InvalidRepeat is a {n,m} quantifier with counts out of order or larger than MaxRepeat
+	InvalidRepeat
+	// BadRange is a set range with its ends out of order or with a class as an end
+	BadRange
+	// EmptySet is a set without any character
+	EmptySet
+	// InvalidGroup is an unknown group kind or an invalid group name
+	InvalidGroup
+	// DuplicateGroupName is a group name used by more than one group
+	DuplicateGroupName
+	// InvalidFlags is a malformed list of flags
+	InvalidFlags
+	// InvalidEscape is an unknown or malformed escape sequence
+	InvalidEscape
+	// UnknownClass is a POSIX class with an unknown name
+	UnknownClass
+	// InvalidUTF8 is a pattern which is not valid UTF-8
+	InvalidUTF8
+)
+
+var errorKindNames = [...]string{
+	UnbalancedParen:    "unbalanced parenthesis",
+	UnbalancedBracket:  "unbalanced square bracket",
+	DanglingQuantifier: "dangling quantifier",
+	InvalidRepeat:      "invalid repeat count",
+	BadRange:           "bad character range",
+	EmptySet:           "empty character set",
+	InvalidGroup:       "invalid group",
+	DuplicateGroupName: "duplicate group name",
+	InvalidFlags:       "invalid flags",
+	InvalidEscape:      "invalid escape sequence",
+	UnknownClass:       "unknown character class",
+	InvalidUTF8:        "invalid UTF-8",
+}
+
+func (k ErrorKind) String() string {
+	if int(k) < len(errorKindNames) {
+		return errorKindNames[k]
+	}
+	return "ErrorKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Error is a syntax error in a pattern, or an error in a regex assembled from code, which has an
+// empty Pattern
+type Error struct {
+	Kind ErrorKind
+	// Offset is the byte offset of the character at fault in the pattern, or the length of the
+	// pattern if it ended too early
+	Offset int
+	// Column is the 1-based position of the character at fault, counted in runes
+	Column  int
+	Pattern string
+	// Message describes the error, without its position
+	Message string
+}
+
+func newError(s string, kind ErrorKind, offset int, message string) *Error {
+	return &Error{
+		Kind:    kind,
+		Offset:  offset,
+		Column:  utf8.RuneCountInString(s[:offset]) + 1,
+		Pattern: s,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Pattern == "" {
+		// the errors in regexes assembled from code have no position
+		return e.Message
+	}
+	return e.Message + " at index " + strconv.Itoa(e.Offset)
+}
+
+// Caret renders the line of the pattern that holds the error, with a caret under the character at
+// fault on the line below it
+func (e *Error) Caret() string {
+	lineStart := strings.LastIndexByte(e.Pattern[:e.Offset], '\n') + 1
+	lineEnd := len(e.Pattern)
+	if i := strings.IndexByte(e.Pattern[e.Offset:], '\n'); i >= 0 {
+		lineEnd = e.Offset + i
+	}
+
+	var sb strings.Builder
+	sb.WriteString(e.Pattern[lineStart:lineEnd])
+	sb.WriteByte('\n')
+	// tabs are kept so that the caret lines up however wide they are rendered
+	for _, c := range e.Pattern[lineStart:e.Offset] {
+		if c == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	sb.WriteByte('^')
+	return sb.String()
+}
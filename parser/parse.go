@@ -1,29 +1,167 @@
 package parser
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/bogdan-deac/regex/ast"
+	"github.com/bogdan-deac/regex/automata"
 	"github.com/bogdan-deac/regex/common/generator"
+	mapset "github.com/deckarep/golang-set/v2"
 )
 
 type Regex = ast.Regex[generator.PrintableInt]
 
+// MaxRepeat is the largest count accepted by the {n,m} quantifiers, and the largest product of the
+// counts of nested quantifiers
+const MaxRepeat = 1000
+
+// Flags change the meaning of some of the operators
+type Flags uint8
+
+const (
+	// MultiLine makes ^ and $ match at the start and end of every line, not only of the input. It is
+	// set inline by the m flag
+	MultiLine Flags = 1 << iota
+	// CaseInsensitive makes letters match regardless of their case. It is set inline by the i flag
+	CaseInsensitive
+	// DotNL makes the . wildcard match newlines as well. It is set inline by the s flag
+	DotNL
+	// Ungreedy swaps the meaning of the greedy and lazy quantifiers, like x* and x*?. It is set
+	// inline by the U flag
+	Ungreedy
+)
+
 type parser struct {
-	index      int
-	groupDepth int
-	inSet      bool
+	index        int
+	initialFlags Flags
+	flags        Flags
+	groupDepth   int
+	groupCount   int
+	groupNames   mapset.Set[string]
+	// inQuote is set inside \Q...\E spans
+	inQuote bool
+	// recovering is set by ParseAll, which collects the errors instead of stopping at the first one
+	recovering bool
+	errors     []*Error
 }
 
 func NewParser() *parser {
 	return &parser{}
 }
+
+// NewParserWithFlags returns a parser for patterns with the given flags set. Flag groups inside the
+// patterns can still change them
+func NewParserWithFlags(flags Flags) *parser {
+	return &parser{initialFlags: flags}
+}
 func (p *parser) Parse(s string) (Regex, error) {
+	p.reset()
+	p.recovering = false
+	return p.parseAlt(s)
+}
+
+// ParseAll parses s like Parse, but does not stop at the first syntax error. After each error it
+// skips to the next |, ) or ] and goes on, so it returns all the errors in the pattern along with
+// a regex made of the parts that could be parsed
+func (p *parser) ParseAll(s string) (Regex, []*Error) {
+	p.reset()
+	p.recovering = true
+	p.errors = nil
+	// parseConcat recovers from every error, as the parser only returns *Error values, so parseAlt
+	// cannot fail here
+	regex, _ := p.parseAlt(s)
+	return regex, p.errors
+}
+
+func (p *parser) reset() {
+	p.flags = p.initialFlags
+	p.inQuote = false
 	p.groupDepth = 0
+	p.groupCount = 0
+	p.groupNames = mapset.NewThreadUnsafeSet[string]()
 	p.index = 0
-	return p.parseAlt(s)
+}
+
+// recover records err when the parser is recovering from errors, or returns it otherwise. All the
+// errors of the parser are *Error values, built by newError
+func (p *parser) recover(err error) error {
+	parseErr, ok := err.(*Error)
+	if !p.recovering || !ok {
+		return err
+	}
+	p.errors = append(p.errors, parseErr)
+	return nil
+}
+
+// resync skips the rest of a branch that could not be parsed, starting at index i: up to the next
+// | or ) which is not inside a group or set that starts after i. A stray ) or ] is skipped as well
+func (p *parser) resync(s string, i int) {
+	p.inQuote = false
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if strings.HasPrefix(s[i:], `\Q`) {
+				end := strings.Index(s[i:], `\E`)
+				if end < 0 {
+					i = len(s)
+					break
+				}
+				i += end
+			}
+			i++
+		case '[':
+			i = closingBracket(s, i+1)
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if p.groupDepth == 0 {
+				i++
+			}
+			p.index = i
+			return
+		case ']':
+			if depth == 0 {
+				p.index = i + 1
+				return
+			}
+		case '|':
+			if depth == 0 {
+				p.index = i
+				return
+			}
+		}
+	}
+	p.index = len(s)
+}
+
+// closingBracket returns the index of the square bracket that closes the set whose contents start
+// at index i, or the length of s if the set is not closed
+func closingBracket(s string, i int) int {
+	if i < len(s) && s[i] == '^' {
+		i++
+	}
+	for ; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == ']':
+			return i
+		case strings.HasPrefix(s[i:], "[:"):
+			if end := strings.Index(s[i+2:], ":]"); end >= 0 {
+				i += end + 3
+			}
+		}
+	}
+	return len(s)
 }
 
 func (p *parser) parseStar(s string) bool {
@@ -57,18 +195,93 @@ func (p *parser) parseMaybe(s string) bool {
 	return false
 }
 
-func (p *parser) parseQuantifier(s string, atom Regex) (Regex, bool) {
+func (p *parser) parseQuantifier(s string, atom Regex) (Regex, bool, error) {
+	if p.inQuote {
+		return nil, false, nil
+	}
 	if p.parseStar(s) {
-		return ast.Star[generator.PrintableInt]{Subexp: atom}, true
+		p.index++
+		return ast.Star[generator.PrintableInt]{Subexp: atom, Lazy: p.parseLazy(s)}, true, nil
 	}
 	if p.parsePlus(s) {
-		return ast.Plus[generator.PrintableInt]{Subexp: atom}, true
+		p.index++
+		return ast.Plus[generator.PrintableInt]{Subexp: atom, Lazy: p.parseLazy(s)}, true, nil
 	}
 	if p.parseMaybe(s) {
-		return ast.Maybe[generator.PrintableInt]{Subexp: atom}, true
+		p.index++
+		return ast.Maybe[generator.PrintableInt]{Subexp: atom, Lazy: p.parseLazy(s)}, true, nil
+	}
+	start := p.index
+	min, max, ok, err := p.parseBounds(s)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		repeat := ast.Repeat[generator.PrintableInt]{Subexp: atom, Min: min, Max: max, Lazy: p.parseLazy(s)}
+		// nested repeats multiply, and so does the size of the automaton
+		if ast.RepeatSize[generator.PrintableInt](repeat, MaxRepeat) > MaxRepeat {
+			return nil, false, newError(s, InvalidRepeat, start, fmt.Sprintf("nested repeat counts exceed the maximum of %d", MaxRepeat))
+		}
+		return repeat, true, nil
 	}
 
-	return nil, false
+	return nil, false, nil
+}
+
+// parseLazy parses the question mark that makes a quantifier lazy. The Ungreedy flag swaps the
+// meaning of the quantifiers with and without it
+func (p *parser) parseLazy(s string) bool {
+	lazy := p.parseMaybe(s)
+	if lazy {
+		p.index++
+	}
+	return lazy != (p.flags&Ungreedy != 0)
+}
+
+// parseBounds parses the {n}, {n,} and {n,m} quantifiers. A brace that does not start a well-formed
+// quantifier is left in place, to be parsed as a literal
+func (p *parser) parseBounds(s string) (int, int, bool, error) {
+	if len(s) <= p.index || s[p.index] != '{' {
+		return 0, 0, false, nil
+	}
+	i := p.index + 1
+	min, i, ok := parseCount(s, i)
+	if !ok {
+		return 0, 0, false, nil
+	}
+	max := min
+	if i < len(s) && s[i] == ',' {
+		i++
+		max = ast.Unbounded
+		if i < len(s) && s[i] != '}' {
+			if max, i, ok = parseCount(s, i); !ok {
+				return 0, 0, false, nil
+			}
+		}
+	}
+	if len(s) <= i || s[i] != '}' {
+		return 0, 0, false, nil
+	}
+
+	if min > MaxRepeat || max > MaxRepeat {
+		return 0, 0, false, newError(s, InvalidRepeat, p.index, fmt.Sprintf("repeat count exceeds the maximum of %d", MaxRepeat))
+	}
+	if max != ast.Unbounded && max < min {
+		return 0, 0, false, newError(s, InvalidRepeat, p.index, "repeat maximum should not be less than repeat minimum "+s[p.index:i+1])
+	}
+	p.index = i + 1
+	return min, max, true, nil
+}
+
+// parseCount parses a decimal number starting at index i. Counts larger than MaxRepeat are clamped
+// to MaxRepeat+1 so that they can be reported without overflowing
+func parseCount(s string, i int) (int, int, bool) {
+	start := i
+	count := 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		count = min(count*10+int(s[i]-'0'), MaxRepeat+1)
+	}
+	return count, i, i > start
 }
 
 func (p *parser) parseRepeat(s string) (Regex, error) {
@@ -76,30 +289,45 @@ func (p *parser) parseRepeat(s string) (Regex, error) {
 	if err != nil {
 		return nil, err
 	}
-	if quantifiedAtom, ok := p.parseQuantifier(s, atom); ok {
-		p.index++
-		return quantifiedAtom, nil
+	if atom == nil {
+		return nil, nil
+	}
+	quantifiedAtom, ok, err := p.parseQuantifier(s, atom)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return p.spanned(quantifiedAtom, atom.Pos().Start), nil
 	}
 
 	return atom, nil
 }
 
 func (p *parser) parseConcat(s string) (Regex, error) {
-	regex, err := p.parseRepeat(s)
-	if err != nil {
-		return nil, err
-	}
+	var regex Regex
 	for {
 		newRegex, err := p.parseRepeat(s)
 		if err != nil {
-			return nil, err
+			if err := p.recover(err); err != nil {
+				return nil, err
+			}
+			p.resync(s, p.errors[len(p.errors)-1].Offset)
+			continue
 		}
 		if newRegex == nil {
+			if regex == nil {
+				return p.spanned(ast.Empty[generator.PrintableInt]{}, p.index), nil
+			}
 			return regex, nil
 		}
+		if regex == nil {
+			regex = newRegex
+			continue
+		}
 		regex = ast.Cat[generator.PrintableInt]{
 			Left:  regex,
 			Right: newRegex,
+			Span:  ast.Span{Start: regex.Pos().Start, End: newRegex.Pos().End},
 		}
 	}
 }
@@ -121,6 +349,7 @@ func (p *parser) parseAlt(s string) (Regex, error) {
 				regex,
 				newRegex,
 			},
+			Span: ast.Span{Start: regex.Pos().Start, End: newRegex.Pos().End},
 		}
 	}
 	return regex, nil
@@ -128,63 +357,425 @@ func (p *parser) parseAlt(s string) (Regex, error) {
 
 func (p *parser) parseGroup(s string) (Regex, error) {
 	if p.index < len(s) && s[p.index] == '(' {
+		start := p.index
 		p.groupDepth++
 		p.index++
+		flags := p.flags
+		capturing, name, err := p.parseGroupKind(s)
+		if err != nil {
+			if err := p.recover(err); err != nil {
+				return nil, err
+			}
+			// parse what follows the group kind as a non-capturing group
+			capturing = false
+			p.resync(s, p.index)
+		}
+		// groups are numbered in the order of their opening parentheses
+		index := 0
+		if capturing {
+			p.groupCount++
+			index = p.groupCount
+		}
 		regex, err := p.parseAlt(s)
 		if err != nil {
 			return nil, err
 		}
 		if p.index < len(s) && s[p.index] == ')' {
 			p.index++
-			p.groupDepth--
-			return regex, nil
+		} else if err := p.recover(newError(s, UnbalancedParen, p.index, "expected closing paren but found none")); err != nil {
+			return nil, err
 		}
-		return nil, errors.New("expected closing bracket but found none at index " + strconv.Itoa(p.index))
+		p.groupDepth--
+		// the flags set inside a group do not apply after it
+		p.flags = flags
+		// the parentheses of a non-capturing group belong to its subexpression
+		if !capturing {
+			return p.spanned(regex, start), nil
+		}
+		return p.spanned(ast.Capture[generator.PrintableInt]{
+			Subexp: regex,
+			Index:  index,
+			Name:   name,
+		}, start), nil
 	}
 	return nil, nil
 }
 
+// parseGroupKind parses what follows an opening parenthesis: "?:" for a non-capturing group,
+// "?flags:" for a non-capturing group with its own flags, "?P<name>" for a named capturing group,
+// or nothing for a plain capturing group
+func (p *parser) parseGroupKind(s string) (bool, string, error) {
+	if !strings.HasPrefix(s[p.index:], "?") {
+		return true, "", nil
+	}
+	if strings.HasPrefix(s[p.index:], "?:") {
+		p.index += 2
+		return false, "", nil
+	}
+	flags, flagsEnd, err := p.parseFlags(s, p.index)
+	if err != nil {
+		return false, "", err
+	}
+	if flagsEnd >= 0 && s[flagsEnd] == ':' {
+		p.flags = flags
+		p.index = flagsEnd + 1
+		return false, "", nil
+	}
+	if !strings.HasPrefix(s[p.index:], "?P<") {
+		return false, "", newError(s, InvalidGroup, p.index, "found unknown group kind")
+	}
+
+	start := p.index
+	p.index += 3
+	end := strings.IndexByte(s[p.index:], '>')
+	if end < 0 {
+		return false, "", newError(s, InvalidGroup, start, "expected closing angle bracket for group name but found none")
+	}
+	name := s[p.index : p.index+end]
+	if !IsGroupName(name) {
+		return false, "", newError(s, InvalidGroup, start, fmt.Sprintf("found invalid group name %q", name))
+	}
+	if p.groupNames.Contains(name) {
+		return false, "", newError(s, DuplicateGroupName, start, fmt.Sprintf("found duplicate group name %q", name))
+	}
+	p.groupNames.Add(name)
+	p.index += end + 1
+	return true, name, nil
+}
+
+// parseFlagGroup parses a (?flags) group, which changes the flags up to the end of the enclosing
+// group
+func (p *parser) parseFlagGroup(s string) (bool, error) {
+	if p.index >= len(s) || s[p.index] != '(' {
+		return false, nil
+	}
+	flags, end, err := p.parseFlags(s, p.index+1)
+	if err != nil || end < 0 || s[end] != ')' {
+		return false, err
+	}
+	p.flags = flags
+	p.index = end + 1
+	return true, nil
+}
+
+// parseFlags parses the flags of a (?flags) or (?flags:re) group, starting at the question mark at
+// index i. The flags before a dash are set and the ones after it are cleared. It returns the
+// resulting flags and the index of the colon or parenthesis that ends them, or -1 if there are no
+// flags to parse
+func (p *parser) parseFlags(s string, i int) (Flags, int, error) {
+	if i >= len(s) || s[i] != '?' {
+		return 0, -1, nil
+	}
+	start := i - 1
+	flags := p.flags
+	clear := false
+	for i++; i < len(s); i++ {
+		var flag Flags
+		switch s[i] {
+		case 'i':
+			flag = CaseInsensitive
+		case 'm':
+			flag = MultiLine
+		case 's':
+			flag = DotNL
+		case 'U':
+			flag = Ungreedy
+		case '-':
+			if clear {
+				return 0, -1, newError(s, InvalidFlags, start, "found invalid flags")
+			}
+			clear = true
+			continue
+		case ':', ')':
+			if s[i-1] == '?' {
+				return 0, -1, nil
+			}
+			if s[i-1] == '-' {
+				return 0, -1, newError(s, InvalidFlags, start, "found invalid flags")
+			}
+			return flags, i, nil
+		default:
+			return 0, -1, nil
+		}
+		if clear {
+			flags &^= flag
+		} else {
+			flags |= flag
+		}
+	}
+	return 0, -1, nil
+}
+
+// IsGroupName reports whether a name is valid for a named group: it is made of Unicode letters,
+// digits and underscores
+func IsGroupName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *parser) parseLiteral(s string) (Regex, error) {
 	if len(s) <= p.index {
 		return nil, nil
 	}
+	if p.inQuote {
+		return p.parseQuoted(s)
+	}
+
+	start := p.index
+	// a well-formed bounded quantifier cannot start an atom, otherwise the brace is a literal
+	if s[p.index] == '{' {
+		if _, _, ok, err := p.parseBounds(s); ok || err != nil {
+			return nil, newError(s, DanglingQuantifier, start, "found unexpected operator")
+		}
+	}
+
+	if assertion, ok := p.parseAssertion(s); ok {
+		return p.spanned(assertion, start), nil
+	}
 
 	switch s[p.index] {
 	case '*', '+', '?':
-		return nil, errors.New("found unexpected operator at index " + strconv.Itoa(p.index))
+		return nil, newError(s, DanglingQuantifier, p.index, "found unexpected operator")
+
 	case '|', '(', '[':
 		return nil, nil
 	case ')':
 		if p.groupDepth == 0 {
-			return nil, errors.New("found unexpected closing paren at index " + strconv.Itoa(p.index))
+			return nil, newError(s, UnbalancedParen, p.index, "found unexpected closing paren")
 		}
 		return nil, nil
 	case ']':
-		if !p.inSet {
-			return nil, errors.New("found unexpected closing square bracket at index " + strconv.Itoa(p.index))
-		}
-		return nil, nil
+		return nil, newError(s, UnbalancedBracket, p.index, "found unexpected closing square bracket")
 	case '.':
 		p.index++
-		return ast.Wildcard[generator.PrintableInt]{}, nil
+		if p.flags&DotNL != 0 {
+			return p.spanned(ast.Wildcard[generator.PrintableInt]{}, start), nil
+		}
+		return p.classRegex(ast.NewClass(ast.CharRange{Lo: '\n', Hi: '\n'}).Negate(), start), nil
 	case '\\':
-		if len(s) <= p.index {
-			return nil, errors.New("found escape operator without argument at index" + strconv.Itoa(p.index))
+		if class, ok, _ := p.parsePerlClass(s); ok {
+			return p.classRegex(class, start), nil
+		}
+		val, err := p.parseEscape(s)
+		if err != nil {
+			return nil, err
 		}
+		return p.literal(val, start), nil
+	default:
+		val, err := p.parseRune(s)
+		if err != nil {
+			return nil, err
+		}
+		return p.literal(val, start), nil
+	}
+}
 
-		p.index++
-		fallthrough
+// literal builds the regex of a single character parsed from index start, which matches all of
+// its cases when the CaseInsensitive flag is set
+func (p *parser) literal(val rune, start int) Regex {
+	if class := p.fold(ast.NewClass(ast.CharRange{Lo: val, Hi: val})); class.Len() > 1 {
+		return p.classRegex(class, start)
+	}
+	return p.spanned(ast.Char[generator.PrintableInt]{
+		Value: val,
+	}, start)
+}
+
+// classRegex builds the regex of a class parsed from index start
+func (p *parser) classRegex(class ast.Class, start int) Regex {
+	return p.spanned(ast.ClassRegex[generator.PrintableInt](class), start)
+}
+
+// spanned sets the span of a node parsed from index start up to the current index
+func (p *parser) spanned(regex Regex, start int) Regex {
+	return ast.WithSpan(regex, ast.Span{Start: start, End: p.index})
+}
+
+// parseEscape parses an escaped character: a control character like \n, a code point given in
+// hexadecimal as in \x41, \x{1F600} and \u00e9 or in octal as in \012, or a punctuation character
+// which loses its special meaning. Other escapes are reserved, so they are reported as errors
+func (p *parser) parseEscape(s string) (rune, error) {
+	start := p.index
+	if len(s) <= p.index+1 {
+		return 0, newError(s, InvalidEscape, start, "found escape operator without argument")
+	}
+	c := s[p.index+1]
+	p.index += 2
+	switch c {
+	case 'a':
+		return '\a', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'v':
+		return '\v', nil
+	case '0':
+		// up to two more octal digits
+		val := rune(0)
+		for i := 0; i < 2 && p.index < len(s) && s[p.index] >= '0' && s[p.index] <= '7'; i++ {
+			val = val*8 + rune(s[p.index]-'0')
+			p.index++
+		}
+		return val, nil
+	case 'x':
+		if p.index < len(s) && s[p.index] == '{' {
+			end := strings.IndexByte(s[p.index:], '}')
+			if end < 0 {
+				return 0, newError(s, InvalidEscape, start, "expected closing brace for hexadecimal escape but found none")
+			}
+			val, ok := parseHex(s[p.index+1 : p.index+end])
+			if !ok {
+				return 0, newError(s, InvalidEscape, start, "found invalid escape sequence "+s[start:p.index+end+1])
+			}
+			p.index += end + 1
+			return val, nil
+		}
+		return p.parseHexDigits(s, start, 2)
+	case 'u':
+		return p.parseHexDigits(s, start, 4)
+	}
+	if c < utf8.RuneSelf && !isAlphanumeric(c) {
+		return rune(c), nil
+	}
+	p.index = start
+	_, size := utf8.DecodeRuneInString(s[start+1:])
+	return 0, newError(s, InvalidEscape, start, "found unknown escape sequence "+s[start:start+1+size])
+}
+
+// parseHexDigits parses the fixed number of hexadecimal digits of a \x or \u escape starting at
+// index start
+func (p *parser) parseHexDigits(s string, start, digits int) (rune, error) {
+	if len(s) < p.index+digits {
+		return 0, newError(s, InvalidEscape, start, "found invalid escape sequence "+s[start:])
+	}
+	val, ok := parseHex(s[p.index : p.index+digits])
+	if !ok {
+		return 0, newError(s, InvalidEscape, start, "found invalid escape sequence "+s[start:p.index+digits])
+	}
+	p.index += digits
+	return val, nil
+}
+
+// parseHex parses a hexadecimal code point
+func parseHex(digits string) (rune, bool) {
+	if digits == "" || len(digits) > 8 {
+		return 0, false
+	}
+	val, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(val)) {
+		return 0, false
+	}
+	return rune(val), true
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// parseQuote parses the \Q that starts a span of literal characters, or the \E that ends it
+func (p *parser) parseQuote(s string) bool {
+	switch {
+	case !p.inQuote && strings.HasPrefix(s[p.index:], `\Q`):
+		p.inQuote = true
+	case p.inQuote && strings.HasPrefix(s[p.index:], `\E`):
+		p.inQuote = false
 	default:
-		val := rune(s[p.index])
+		return false
+	}
+	p.index += 2
+	return true
+}
+
+// parseQuoted parses a character of a \Q...\E span. The span runs up to the end of the pattern if
+// it is not closed
+func (p *parser) parseQuoted(s string) (Regex, error) {
+	start := p.index
+	val, err := p.parseRune(s)
+	if err != nil {
+		return nil, err
+	}
+	literal := p.literal(val, start)
+	// a quantifier right after the span applies to its last character
+	p.parseQuote(s)
+	return literal, nil
+}
+
+// fold adds the other cases of the characters of a class when the CaseInsensitive flag is set
+func (p *parser) fold(class ast.Class) ast.Class {
+	if p.flags&CaseInsensitive == 0 {
+		return class
+	}
+	return class.Fold()
+}
+
+// parseAssertion parses the ^ and $ anchors, which match at line boundaries in multi-line mode, the
+// \A and \z anchors, which always match at the boundaries of the input, and the \b and \B word
+// boundaries
+func (p *parser) parseAssertion(s string) (Regex, bool) {
+	var kind automata.Assertion
+	switch {
+	case s[p.index] == '^' && p.flags&MultiLine != 0:
+		kind = automata.BeginLine
+	case s[p.index] == '^', strings.HasPrefix(s[p.index:], `\A`):
+		kind = automata.BeginText
+	case s[p.index] == '$' && p.flags&MultiLine != 0:
+		kind = automata.EndLine
+	case s[p.index] == '$', strings.HasPrefix(s[p.index:], `\z`):
+		kind = automata.EndText
+	case strings.HasPrefix(s[p.index:], `\b`):
+		kind = automata.WordBoundary
+	case strings.HasPrefix(s[p.index:], `\B`):
+		kind = automata.NoWordBoundary
+	default:
+		return nil, false
+	}
+	if s[p.index] == '\\' {
 		p.index++
-		return ast.Char[generator.PrintableInt]{
-			// TBD unicode suport
-			Value: val,
-		}, nil
 	}
+	p.index++
+	return ast.Assertion[generator.PrintableInt]{Kind: kind}, true
+}
+
+// parseRune decodes the UTF-8 encoded character at the current index
+func (p *parser) parseRune(s string) (rune, error) {
+	val, size := utf8.DecodeRuneInString(s[p.index:])
+	if val == utf8.RuneError && size <= 1 {
+		return 0, newError(s, InvalidUTF8, p.index, "found invalid UTF-8")
+	}
+	p.index += size
+	return val, nil
 }
 
 func (p *parser) parseAtom(s string) (Regex, error) {
+	// flag groups and quotes hold no subexpression, they only change how what follows them is parsed
+	for {
+		if p.parseQuote(s) {
+			continue
+		}
+		if p.inQuote {
+			break
+		}
+		ok, err := p.parseFlagGroup(s)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+
 	// attempt parsing a literal
 	regex, err := p.parseLiteral(s)
 	if err != nil {
@@ -211,78 +802,162 @@ func (p *parser) parseAtom(s string) (Regex, error) {
 	return regex, nil
 }
 func (p *parser) parseSet(s string) (Regex, error) {
-	if p.index < len(s) && s[p.index] == '[' {
-		p.inSet = true
-		p.index++
+	if p.index >= len(s) || s[p.index] != '[' {
+		return nil, nil
+	}
+	setStart := p.index
+	p.index++
 
-		if p.index < len(s) && s[p.index] == '^' {
-			return nil, errors.New("negated character sets are not yet implemented")
-		}
+	negated := false
+	if p.index < len(s) && s[p.index] == '^' {
+		negated = true
+		p.index++
+	}
 
-		regex, err := p.parseSetAtom(s)
-		if err != nil {
-			return nil, err
-		}
-		or := ast.Or[generator.PrintableInt]{
-			Branches: []ast.Regex[generator.PrintableInt]{
-				regex,
-			},
-		}
-		for {
-			newRegex, err := p.parseSetAtom(s)
+	var class ast.Class
+	empty := true
+	for {
+		if p.index >= len(s) {
+			err := p.recover(newError(s, UnbalancedBracket, p.index, "expected closing square bracket but found none"))
 			if err != nil {
 				return nil, err
 			}
-			if newRegex != nil {
-				or.Branches = append(or.Branches, newRegex)
+			break
+		}
+		if s[p.index] == ']' {
+			if empty {
+				if err := p.recover(newError(s, EmptySet, p.index, "found empty character set")); err != nil {
+					return nil, err
+				}
 			}
-			if p.index < len(s) && s[p.index] == ']' {
-				p.inSet = false
-				p.index++
-				break
+			p.index++
+			break
+		}
+		start := p.index
+		atom, err := p.parseSetAtom(s)
+		if err != nil {
+			if err := p.recover(err); err != nil {
+				return nil, err
 			}
+			// skip the rest of the set
+			p.index = min(closingBracket(s, start)+1, len(s))
+			break
 		}
-		return or, nil
+		class = class.Union(atom)
+		empty = false
 	}
 
-	return nil, nil
+	if negated {
+		class = class.Negate()
+	}
+	return p.classRegex(class, setStart), nil
 }
 
-func (p *parser) parseRange(s string) (Regex, error) {
-	if p.index+1 >= len(s) || s[p.index+1] != '-' {
-		return nil, nil
+// parseSetChar parses a single, possibly escaped, character inside a set. Operators lose their
+// special meaning inside sets, so they are parsed as plain characters
+func (p *parser) parseSetChar(s string) (rune, error) {
+	if s[p.index] != '\\' {
+		return p.parseRune(s)
 	}
-	rangeStart := s[p.index]
-	rangeEnd := s[p.index+2]
-	if rangeEnd < rangeStart {
-		return nil, fmt.Errorf("range start should not be less than range end %s", s[p.index:p.index+2])
+	if p.index+1 < len(s) {
+		if _, ok := ast.PerlClass(rune(s[p.index+1])); ok {
+			return 0, newError(s, BadRange, p.index, "found character class in range")
+		}
 	}
+	return p.parseEscape(s)
+}
 
-	or := ast.Or[generator.PrintableInt]{
-		Branches: []ast.Regex[generator.PrintableInt]{},
+func (p *parser) parseRange(s string) (ast.Class, bool, error) {
+	start := p.index
+	rangeStart, err := p.parseSetChar(s)
+	if err != nil {
+		return ast.Class{}, false, err
+	}
+	// a dash right before the closing bracket is a plain character
+	if p.index+1 >= len(s) || s[p.index] != '-' || s[p.index+1] == ']' {
+		p.index = start
+		return ast.Class{}, false, nil
+	}
+	// skip the dash
+	p.index++
+	rangeEnd, err := p.parseSetChar(s)
+	if err != nil {
+		return ast.Class{}, false, err
+	}
+	if rangeEnd < rangeStart {
+		return ast.Class{}, false, newError(s, BadRange, start, "range end should not be less than range start "+s[start:p.index])
 	}
 
-	for c := rangeStart; c <= rangeEnd; c++ {
-		or.Branches = append(or.Branches, ast.Char[generator.PrintableInt]{
-			Value: rune(c),
-		})
+	return p.fold(ast.NewClass(ast.CharRange{Lo: rangeStart, Hi: rangeEnd})), true, nil
+}
+
+// parsePerlClass parses the \d, \s and \w shorthand classes, and their negations. As for all
+// the classes, the cases are added before negating, so \W excludes all the cases of \w
+func (p *parser) parsePerlClass(s string) (ast.Class, bool, error) {
+	if p.index+1 >= len(s) || s[p.index] != '\\' {
+		return ast.Class{}, false, nil
 	}
-	p.index += 3
-	return or, nil
+	letter := rune(s[p.index+1])
+	class, ok := ast.PerlClass(unicode.ToLower(letter))
+	if !ok {
+		return ast.Class{}, false, nil
+	}
+	p.index += 2
+	class = p.fold(class)
+	if unicode.IsUpper(letter) {
+		class = class.Negate()
+	}
+	return class, true, nil
+}
 
+// parsePosixClass parses a [:name:] or a negated [:^name:] bracket expression. A bracket that does
+// not start one is a plain character
+func (p *parser) parsePosixClass(s string) (ast.Class, bool, error) {
+	if !strings.HasPrefix(s[p.index:], "[:") {
+		return ast.Class{}, false, nil
+	}
+	end := strings.Index(s[p.index+2:], ":]")
+	if end < 0 {
+		return ast.Class{}, false, nil
+	}
+	name := s[p.index+2 : p.index+2+end]
+	negated := strings.HasPrefix(name, "^")
+	class, ok := ast.PosixClass(strings.TrimPrefix(name, "^"))
+	if !ok {
+		return ast.Class{}, false, newError(s, UnknownClass, p.index, fmt.Sprintf("found unknown POSIX class %q", name))
+	}
+	p.index += end + 4
+	class = p.fold(class)
+	if negated {
+		class = class.Negate()
+	}
+	return class, true, nil
 }
-func (p *parser) parseSetAtom(s string) (Regex, error) {
-	regex, err := p.parseRange(s)
+
+func (p *parser) parseSetAtom(s string) (ast.Class, error) {
+	start := p.index
+	class, ok, err := p.parsePerlClass(s)
+	if err == nil && !ok {
+		class, ok, err = p.parsePosixClass(s)
+	}
 	if err != nil {
-		return nil, err
+		return ast.Class{}, err
 	}
-	if regex != nil {
-		return regex, nil
+	if ok {
+		// classes cannot be range ends
+		if p.index+1 < len(s) && s[p.index] == '-' && s[p.index+1] != ']' {
+			return ast.Class{}, newError(s, BadRange, start, "found character class in range")
+		}
+		return class, nil
+	}
+
+	class, ok, err = p.parseRange(s)
+	if err != nil || ok {
+		return class, err
 	}
-	regex, err = p.parseLiteral(s)
+	c, err := p.parseSetChar(s)
 	if err != nil {
-		return nil, err
+		return ast.Class{}, err
 	}
-
-	return regex, nil
+	return p.fold(ast.NewClass(ast.CharRange{Lo: c, Hi: c})), nil
 }
@@ -1,9 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/bogdan-deac/regex/ast"
+	"github.com/bogdan-deac/regex/automata"
 	"github.com/bogdan-deac/regex/common/generator"
 	"github.com/stretchr/testify/assert"
 )
@@ -49,29 +51,71 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			reS:            "(a)",
+			reS: "(a)",
+			expectedResult: ast.Capture[generator.PrintableInt]{
+				Subexp: ast.Char[generator.PrintableInt]{Value: 'a'},
+				Index:  1,
+			},
+		},
+		{
+			reS:            "(?:a)",
 			expectedResult: ast.Char[generator.PrintableInt]{Value: 'a'},
 		},
 		{
 			reS: "(a|b)",
-			expectedResult: ast.Or[generator.PrintableInt]{
-				Branches: []ast.Regex[generator.PrintableInt]{
-					ast.Char[generator.PrintableInt]{Value: 'a'},
-					ast.Char[generator.PrintableInt]{Value: 'b'},
+			expectedResult: ast.Capture[generator.PrintableInt]{
+				Subexp: ast.Or[generator.PrintableInt]{
+					Branches: []ast.Regex[generator.PrintableInt]{
+						ast.Char[generator.PrintableInt]{Value: 'a'},
+						ast.Char[generator.PrintableInt]{Value: 'b'},
+					},
 				},
+				Index: 1,
 			},
 		},
 		{
 			reS: "(a|b)*",
 			expectedResult: ast.Star[generator.PrintableInt]{
-				Subexp: ast.Or[generator.PrintableInt]{
-					Branches: []ast.Regex[generator.PrintableInt]{
-						ast.Char[generator.PrintableInt]{Value: 'a'},
-						ast.Char[generator.PrintableInt]{Value: 'b'},
+				Subexp: ast.Capture[generator.PrintableInt]{
+					Subexp: ast.Or[generator.PrintableInt]{
+						Branches: []ast.Regex[generator.PrintableInt]{
+							ast.Char[generator.PrintableInt]{Value: 'a'},
+							ast.Char[generator.PrintableInt]{Value: 'b'},
+						},
+					},
+					Index: 1,
+				},
+			},
+		},
+		{
+			reS: "((a)b)(?P<last>c)",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Capture[generator.PrintableInt]{
+					Subexp: ast.Cat[generator.PrintableInt]{
+						Left: ast.Capture[generator.PrintableInt]{
+							Subexp: ast.Char[generator.PrintableInt]{Value: 'a'},
+							Index:  2,
+						},
+						Right: ast.Char[generator.PrintableInt]{Value: 'b'},
 					},
+					Index: 1,
+				},
+				Right: ast.Capture[generator.PrintableInt]{
+					Subexp: ast.Char[generator.PrintableInt]{Value: 'c'},
+					Index:  3,
+					Name:   "last",
 				},
 			},
 		},
+		{
+			// names are made of Unicode letters and digits
+			reS: "(?P<名前_٣>x)",
+			expectedResult: ast.Capture[generator.PrintableInt]{
+				Subexp: ast.Char[generator.PrintableInt]{Value: 'x'},
+				Index:  1,
+				Name:   "名前_٣",
+			},
+		},
 		{
 			reS: "a*|b",
 			expectedResult: ast.Or[generator.PrintableInt]{
@@ -123,7 +167,7 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			reS: "(a|b|c)",
+			reS: "(?:a|b|c)",
 			expectedResult: ast.Or[generator.PrintableInt]{
 				Branches: []ast.Regex[generator.PrintableInt]{
 					ast.Or[generator.PrintableInt]{
@@ -137,7 +181,7 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			reS: "(aa|bb|cc)",
+			reS: "(?:aa|bb|cc)",
 			expectedResult: ast.Or[generator.PrintableInt]{
 				Branches: []ast.Regex[generator.PrintableInt]{
 					ast.Or[generator.PrintableInt]{
@@ -159,12 +203,564 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			reS:            "[^abc]",
+			expectedResult: negatedClass(ast.CharRange{Lo: 'a', Hi: 'c'}),
+		},
+		{
+			reS: "[^a-c]x",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left:  negatedClass(ast.CharRange{Lo: 'a', Hi: 'c'}),
+				Right: ast.Char[generator.PrintableInt]{Value: 'x'},
+			},
+		},
+		{
+			reS:            `[^"\\]`,
+			expectedResult: negatedClass(ast.CharRange{Lo: '"', Hi: '"'}, ast.CharRange{Lo: '\\', Hi: '\\'}),
+		},
+		{
+			reS: "a{3}",
+			expectedResult: ast.Repeat[generator.PrintableInt]{
+				Subexp: ast.Char[generator.PrintableInt]{Value: 'a'},
+				Min:    3,
+				Max:    3,
+			},
+		},
+		{
+			reS: "a{2,}",
+			expectedResult: ast.Repeat[generator.PrintableInt]{
+				Subexp: ast.Char[generator.PrintableInt]{Value: 'a'},
+				Min:    2,
+				Max:    ast.Unbounded,
+			},
+		},
+		{
+			reS: "(?:a|b){0,5}",
+			expectedResult: ast.Repeat[generator.PrintableInt]{
+				Subexp: ast.Or[generator.PrintableInt]{
+					Branches: []ast.Regex[generator.PrintableInt]{
+						ast.Char[generator.PrintableInt]{Value: 'a'},
+						ast.Char[generator.PrintableInt]{Value: 'b'},
+					},
+				},
+				Min: 0,
+				Max: 5,
+			},
+		},
+		{
+			reS: "a{,2}",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Cat[generator.PrintableInt]{
+					Left: ast.Cat[generator.PrintableInt]{
+						Left: ast.Cat[generator.PrintableInt]{
+							Left:  ast.Char[generator.PrintableInt]{Value: 'a'},
+							Right: ast.Char[generator.PrintableInt]{Value: '{'},
+						},
+						Right: ast.Char[generator.PrintableInt]{Value: ','},
+					},
+					Right: ast.Char[generator.PrintableInt]{Value: '2'},
+				},
+				Right: ast.Char[generator.PrintableInt]{Value: '}'},
+			},
+		},
+		{
+			reS: "é+",
+			expectedResult: ast.Plus[generator.PrintableInt]{
+				Subexp: ast.Char[generator.PrintableInt]{Value: 'é'},
+			},
+		},
+		{
+			reS:            "[α-γ]",
+			expectedResult: charClass(ast.CharRange{Lo: 'α', Hi: 'γ'}),
+		},
+		{
+			reS:            "[^日本]",
+			expectedResult: negatedClass(ast.CharRange{Lo: '日', Hi: '日'}, ast.CharRange{Lo: '本', Hi: '本'}),
+		},
+		{
+			reS:            `\d`,
+			expectedResult: ast.ClassRegex[generator.PrintableInt](ast.NewClass(ast.CharRange{Lo: '0', Hi: '9'})),
+		},
+		{
+			reS:            `\S`,
+			expectedResult: negatedClass(ast.CharRange{Lo: '\t', Hi: '\n'}, ast.CharRange{Lo: '\f', Hi: '\r'}, ast.CharRange{Lo: ' ', Hi: ' '}),
+		},
+		{
+			reS: `[\d_-]`,
+			expectedResult: ast.ClassRegex[generator.PrintableInt](ast.NewClass(
+				ast.CharRange{Lo: '-', Hi: '-'},
+				ast.CharRange{Lo: '0', Hi: '9'},
+				ast.CharRange{Lo: '_', Hi: '_'},
+			)),
+		},
+		{
+			reS:            `[\D5]`,
+			expectedResult: negatedClass(ast.CharRange{Lo: '0', Hi: '4'}, ast.CharRange{Lo: '6', Hi: '9'}),
+		},
+		{
+			reS:            `[^\W]`,
+			expectedResult: ast.ClassRegex[generator.PrintableInt](ast.NewClass(ast.CharRange{Lo: '0', Hi: '9'}, ast.CharRange{Lo: 'A', Hi: 'Z'}, ast.CharRange{Lo: '_', Hi: '_'}, ast.CharRange{Lo: 'a', Hi: 'z'})),
+		},
+		{
+			reS:            "[[:xdigit:]]",
+			expectedResult: ast.ClassRegex[generator.PrintableInt](ast.NewClass(ast.CharRange{Lo: '0', Hi: '9'}, ast.CharRange{Lo: 'A', Hi: 'F'}, ast.CharRange{Lo: 'a', Hi: 'f'})),
+		},
+		{
+			reS:            "[^[:digit:][:blank:]]",
+			expectedResult: negatedClass(ast.CharRange{Lo: '\t', Hi: '\t'}, ast.CharRange{Lo: ' ', Hi: ' '}, ast.CharRange{Lo: '0', Hi: '9'}),
+		},
+		{
+			reS: "^a$",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Cat[generator.PrintableInt]{
+					Left:  ast.Assertion[generator.PrintableInt]{Kind: automata.BeginText},
+					Right: ast.Char[generator.PrintableInt]{Value: 'a'},
+				},
+				Right: ast.Assertion[generator.PrintableInt]{Kind: automata.EndText},
+			},
+		},
+		{
+			reS: `\A\$\z`,
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Cat[generator.PrintableInt]{
+					Left:  ast.Assertion[generator.PrintableInt]{Kind: automata.BeginText},
+					Right: ast.Char[generator.PrintableInt]{Value: '$'},
+				},
+				Right: ast.Assertion[generator.PrintableInt]{Kind: automata.EndText},
+			},
+		},
+		{
+			reS: `\bx\B`,
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Cat[generator.PrintableInt]{
+					Left:  ast.Assertion[generator.PrintableInt]{Kind: automata.WordBoundary},
+					Right: ast.Char[generator.PrintableInt]{Value: 'x'},
+				},
+				Right: ast.Assertion[generator.PrintableInt]{Kind: automata.NoWordBoundary},
+			},
+		},
+		{
+			reS:            ".",
+			expectedResult: negatedClass(ast.CharRange{Lo: '\n', Hi: '\n'}),
+		},
+		{
+			reS:            "(?s).",
+			expectedResult: ast.Wildcard[generator.PrintableInt]{},
+		},
+		{
+			reS: "(?i:k)a",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left:  charClass(ast.CharRange{Lo: 'K', Hi: 'K'}, ast.CharRange{Lo: 'k', Hi: 'k'}, ast.CharRange{Lo: '\u212a', Hi: '\u212a'}),
+				Right: ast.Char[generator.PrintableInt]{Value: 'a'},
+			},
+		},
+		{
+			reS:            "(?i)1",
+			expectedResult: ast.Char[generator.PrintableInt]{Value: '1'},
+		},
+		{
+			reS: "a*?b+",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left:  ast.Star[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: 'a'}, Lazy: true},
+				Right: ast.Plus[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: 'b'}},
+			},
+		},
+		{
+			reS: "(?U)a*?b{2}",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left:  ast.Star[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: 'a'}},
+				Right: ast.Repeat[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: 'b'}, Min: 2, Max: 2, Lazy: true},
+			},
+		},
+		{
+			reS:            "[$^]",
+			expectedResult: charClass(ast.CharRange{Lo: '$', Hi: '$'}, ast.CharRange{Lo: '^', Hi: '^'}),
+		},
+		{
+			reS: `\t\x41\x{1F600}\u00e9\012\0`,
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Cat[generator.PrintableInt]{
+					Left: ast.Cat[generator.PrintableInt]{
+						Left: ast.Cat[generator.PrintableInt]{
+							Left: ast.Cat[generator.PrintableInt]{
+								Left:  ast.Char[generator.PrintableInt]{Value: '\t'},
+								Right: ast.Char[generator.PrintableInt]{Value: 'A'},
+							},
+							Right: ast.Char[generator.PrintableInt]{Value: '😀'},
+						},
+						Right: ast.Char[generator.PrintableInt]{Value: 'é'},
+					},
+					Right: ast.Char[generator.PrintableInt]{Value: '\n'},
+				},
+				Right: ast.Char[generator.PrintableInt]{Value: 0},
+			},
+		},
+		{
+			reS:            `[\n-\r\]]`,
+			expectedResult: charClass(ast.CharRange{Lo: '\n', Hi: '\r'}, ast.CharRange{Lo: ']', Hi: ']'}),
+		},
+		{
+			reS: `\Q.*\E+`,
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left:  ast.Char[generator.PrintableInt]{Value: '.'},
+				Right: ast.Plus[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: '*'}},
+			},
+		},
+		{
+			reS: `a\Q\Eb\Q(|`,
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Cat[generator.PrintableInt]{
+					Left: ast.Cat[generator.PrintableInt]{
+						Left:  ast.Char[generator.PrintableInt]{Value: 'a'},
+						Right: ast.Char[generator.PrintableInt]{Value: 'b'},
+					},
+					Right: ast.Char[generator.PrintableInt]{Value: '('},
+				},
+				Right: ast.Char[generator.PrintableInt]{Value: '|'},
+			},
+		},
+		{
+			reS:            "",
+			expectedResult: ast.Empty[generator.PrintableInt]{},
+		},
+		{
+			reS: "a|",
+			expectedResult: ast.Or[generator.PrintableInt]{
+				Branches: []ast.Regex[generator.PrintableInt]{
+					ast.Char[generator.PrintableInt]{Value: 'a'},
+					ast.Empty[generator.PrintableInt]{},
+				},
+			},
+		},
+		{
+			reS: "(|x)(?:)y",
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Cat[generator.PrintableInt]{
+					Left: ast.Capture[generator.PrintableInt]{
+						Subexp: ast.Or[generator.PrintableInt]{
+							Branches: []ast.Regex[generator.PrintableInt]{
+								ast.Empty[generator.PrintableInt]{},
+								ast.Char[generator.PrintableInt]{Value: 'x'},
+							},
+						},
+						Index: 1,
+					},
+					Right: ast.Empty[generator.PrintableInt]{},
+				},
+				Right: ast.Char[generator.PrintableInt]{Value: 'y'},
+			},
+		},
+		{
+			reS:            `[^\d\D]`,
+			expectedResult: ast.Nothing[generator.PrintableInt]{},
+		},
 	}
 
 	p := NewParser()
 	for _, tc := range tt {
 		exp, err := p.Parse(tc.reS)
 		assert.Nil(t, err)
-		assert.Equal(t, tc.expectedResult, exp)
+		assert.Equal(t, tc.expectedResult, ast.StripSpans(exp))
+	}
+}
+
+func TestParseMultiLine(t *testing.T) {
+	p := NewParserWithFlags(MultiLine)
+	regex, err := p.Parse(`^\A$\z`)
+	assert.Nil(t, err)
+	assert.Equal(t, ast.Cat[generator.PrintableInt]{
+		Left: ast.Cat[generator.PrintableInt]{
+			Left: ast.Cat[generator.PrintableInt]{
+				Left:  ast.Assertion[generator.PrintableInt]{Kind: automata.BeginLine},
+				Right: ast.Assertion[generator.PrintableInt]{Kind: automata.BeginText},
+			},
+			Right: ast.Assertion[generator.PrintableInt]{Kind: automata.EndLine},
+		},
+		Right: ast.Assertion[generator.PrintableInt]{Kind: automata.EndText},
+	}, ast.StripSpans(regex))
+}
+
+func TestParseErrors(t *testing.T) {
+	tt := []string{
+		"[abc",
+		"[^",
+		"[]",
+		"[z-a]",
+		"a{3,2}",
+		"a{1001}",
+		"a{99999999999999999999}",
+		"{2}",
+		"a{2}{3}",
+		"a*{2}",
+		"(?P<x>a)(?P<x>b)",
+		"(?P<>a)",
+		"(?P<a-b>a)",
+		"(?P<x",
+		"(?x)",
+		"a\xffb",
+		"[\xff]",
+		"[ω-α]",
+		"[[:letter:]]",
+		`[a-\d]`,
+		`[\w-z]`,
+		"(?i-)",
+		"(?i-m-s)",
+		"(?i",
+		"(?)",
+		"(?i)*",
+		"a**",
+		`\k`,
+		`\1`,
+		`\E`,
+		`\é`,
+		`[\k]`,
+		`[\Qa\E]`,
+		`\xZ1`,
+		`\x4`,
+		`\x{}`,
+		`\x{110000}`,
+		`\x{D800}`,
+		`\x{41`,
+		`\u12`,
+	}
+
+	p := NewParser()
+	for _, reS := range tt {
+		_, err := p.Parse(reS)
+		assert.NotNilf(t, err, "Expected %s to fail parsing", reS)
 	}
 }
+
+func TestParseNestedRepeats(t *testing.T) {
+	tt := []struct {
+		reS   string
+		valid bool
+	}{
+		{reS: "(a{10}){100}", valid: true},
+		{reS: "((a{2}){20}){25}", valid: true},
+		{reS: "(a{1000})*", valid: true},
+		{reS: "(a{0}){1000}", valid: true},
+		{reS: "(a{100}|b{10}){10}", valid: true},
+		{reS: "a{1000}b{1000}", valid: true},
+		{reS: "(a{10}){101}"},
+		{reS: "((a{2}){20}){26}"},
+		{reS: "(a{2,}){501}"},
+		{reS: "(a{2}){1,501}"},
+		{reS: "(a|b{11}){100}"},
+		{reS: "(a{1000}){1000}"},
+	}
+
+	p := NewParser()
+	for _, tc := range tt {
+		_, err := p.Parse(tc.reS)
+		if tc.valid {
+			assert.Nil(t, err, tc.reS)
+			continue
+		}
+		var parseErr *Error
+		if assert.Truef(t, errors.As(err, &parseErr), "Expected %s to fail parsing", tc.reS) {
+			assert.Equal(t, InvalidRepeat, parseErr.Kind, tc.reS)
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tt := []struct {
+		reS            string
+		expectedKind   ErrorKind
+		expectedOffset int
+		expectedColumn int
+	}{
+		{reS: "(ab", expectedKind: UnbalancedParen, expectedOffset: 3, expectedColumn: 4},
+		{reS: "ab)", expectedKind: UnbalancedParen, expectedOffset: 2, expectedColumn: 3},
+		{reS: "é[a", expectedKind: UnbalancedBracket, expectedOffset: 4, expectedColumn: 4},
+		{reS: "a]", expectedKind: UnbalancedBracket, expectedOffset: 1, expectedColumn: 2},
+		{reS: "αβ|*", expectedKind: DanglingQuantifier, expectedOffset: 5, expectedColumn: 4},
+		{reS: "a{3,2}", expectedKind: InvalidRepeat, expectedOffset: 1, expectedColumn: 2},
+		{reS: "(a{10}){101}", expectedKind: InvalidRepeat, expectedOffset: 7, expectedColumn: 8},
+		{reS: "[αz-a]", expectedKind: BadRange, expectedOffset: 3, expectedColumn: 3},
+		{reS: "[]", expectedKind: EmptySet, expectedOffset: 1, expectedColumn: 2},
+		{reS: "(?P<a-b>a)", expectedKind: InvalidGroup, expectedOffset: 1, expectedColumn: 2},
+		{reS: "(?P<x>a)(?P<x>b)", expectedKind: DuplicateGroupName, expectedOffset: 9, expectedColumn: 10},
+		{reS: "(?i-)", expectedKind: InvalidFlags, expectedOffset: 0, expectedColumn: 1},
+		{reS: `a\k`, expectedKind: InvalidEscape, expectedOffset: 1, expectedColumn: 2},
+		{reS: "[[:letter:]]", expectedKind: UnknownClass, expectedOffset: 1, expectedColumn: 2},
+		{reS: "a\xffb", expectedKind: InvalidUTF8, expectedOffset: 1, expectedColumn: 2},
+	}
+
+	p := NewParser()
+	for _, tc := range tt {
+		_, err := p.Parse(tc.reS)
+		var parseErr *Error
+		if assert.Truef(t, errors.As(err, &parseErr), "Expected %s to fail parsing", tc.reS) {
+			assert.Equal(t, tc.expectedKind, parseErr.Kind, tc.reS)
+			assert.Equal(t, tc.expectedOffset, parseErr.Offset, tc.reS)
+			assert.Equal(t, tc.expectedColumn, parseErr.Column, tc.reS)
+			assert.Equal(t, tc.reS, parseErr.Pattern)
+		}
+	}
+}
+
+func TestErrorCaret(t *testing.T) {
+	p := NewParser()
+	_, err := p.Parse("αβ|a**")
+	assert.EqualError(t, err, "found unexpected operator at index 7")
+	assert.Equal(t, "αβ|a**\n     ^", err.(*Error).Caret())
+
+	_, err = p.Parse("a|\n\tb{2,1}|c")
+	assert.Equal(t, "\tb{2,1}|c\n\t ^", err.(*Error).Caret())
+
+	_, err = p.Parse("(a")
+	assert.Equal(t, "(a\n  ^", err.(*Error).Caret())
+}
+
+func TestParseAll(t *testing.T) {
+	type position struct {
+		kind   ErrorKind
+		offset int
+	}
+	tt := []struct {
+		reS            string
+		expectedResult Regex
+		expectedErrors []position
+	}{
+		{
+			reS: "a**b|xc{3,2}d|[a-cz-a]e|(f",
+			expectedResult: ast.Or[generator.PrintableInt]{
+				Branches: []ast.Regex[generator.PrintableInt]{
+					ast.Or[generator.PrintableInt]{
+						Branches: []ast.Regex[generator.PrintableInt]{
+							ast.Or[generator.PrintableInt]{
+								Branches: []ast.Regex[generator.PrintableInt]{
+									ast.Star[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: 'a'}},
+									ast.Char[generator.PrintableInt]{Value: 'x'},
+								},
+							},
+							ast.Cat[generator.PrintableInt]{
+								Left:  charClass(ast.CharRange{Lo: 'a', Hi: 'c'}),
+								Right: ast.Char[generator.PrintableInt]{Value: 'e'},
+							},
+						},
+					},
+					ast.Capture[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: 'f'}, Index: 1},
+				},
+			},
+			expectedErrors: []position{{DanglingQuantifier, 2}, {InvalidRepeat, 7}, {BadRange, 18}, {UnbalancedParen, 26}},
+		},
+		{
+			reS: `a)b]c|[x\k]d|x(?P<>a)`,
+			expectedResult: ast.Or[generator.PrintableInt]{
+				Branches: []ast.Regex[generator.PrintableInt]{
+					ast.Or[generator.PrintableInt]{
+						Branches: []ast.Regex[generator.PrintableInt]{
+							ast.Cat[generator.PrintableInt]{
+								Left: ast.Cat[generator.PrintableInt]{
+									Left:  ast.Char[generator.PrintableInt]{Value: 'a'},
+									Right: ast.Char[generator.PrintableInt]{Value: 'b'},
+								},
+								Right: ast.Char[generator.PrintableInt]{Value: 'c'},
+							},
+							ast.Cat[generator.PrintableInt]{
+								Left:  charClass(ast.CharRange{Lo: 'x', Hi: 'x'}),
+								Right: ast.Char[generator.PrintableInt]{Value: 'd'},
+							},
+						},
+					},
+					ast.Cat[generator.PrintableInt]{
+						Left:  ast.Char[generator.PrintableInt]{Value: 'x'},
+						Right: ast.Empty[generator.PrintableInt]{},
+					},
+				},
+			},
+			expectedErrors: []position{{UnbalancedParen, 1}, {UnbalancedBracket, 3}, {InvalidEscape, 8}, {InvalidGroup, 15}},
+		},
+		{
+			reS: `(a{2}|\Q(|\E)b`,
+			expectedResult: ast.Cat[generator.PrintableInt]{
+				Left: ast.Capture[generator.PrintableInt]{
+					Subexp: ast.Or[generator.PrintableInt]{
+						Branches: []ast.Regex[generator.PrintableInt]{
+							ast.Repeat[generator.PrintableInt]{Subexp: ast.Char[generator.PrintableInt]{Value: 'a'}, Min: 2, Max: 2},
+							ast.Cat[generator.PrintableInt]{
+								Left:  ast.Char[generator.PrintableInt]{Value: '('},
+								Right: ast.Char[generator.PrintableInt]{Value: '|'},
+							},
+						},
+					},
+					Index: 1,
+				},
+				Right: ast.Char[generator.PrintableInt]{Value: 'b'},
+			},
+		},
+	}
+
+	p := NewParser()
+	for _, tc := range tt {
+		regex, errs := p.ParseAll(tc.reS)
+		assert.Equal(t, tc.expectedResult, ast.StripSpans(regex), tc.reS)
+		var positions []position
+		for _, err := range errs {
+			positions = append(positions, position{err.Kind, err.Offset})
+		}
+		assert.Equal(t, tc.expectedErrors, positions, tc.reS)
+	}
+
+	// the parser stops at the first error again once it is done recovering
+	_, err := p.Parse("a**b|c{3,2}")
+	assert.EqualError(t, err, "found unexpected operator at index 2")
+}
+
+func TestParseSpans(t *testing.T) {
+	p := NewParser()
+	regex, err := p.Parse("x(?:ab)*|([ab])+?")
+	assert.Nil(t, err)
+	assert.Equal(t, ast.Or[generator.PrintableInt]{
+		Branches: []ast.Regex[generator.PrintableInt]{
+			ast.Cat[generator.PrintableInt]{
+				Left: ast.Char[generator.PrintableInt]{Value: 'x', Span: ast.Span{Start: 0, End: 1}},
+				Right: ast.Star[generator.PrintableInt]{
+					Subexp: ast.Cat[generator.PrintableInt]{
+						Left:  ast.Char[generator.PrintableInt]{Value: 'a', Span: ast.Span{Start: 4, End: 5}},
+						Right: ast.Char[generator.PrintableInt]{Value: 'b', Span: ast.Span{Start: 5, End: 6}},
+						Span:  ast.Span{Start: 1, End: 7},
+					},
+					Span: ast.Span{Start: 1, End: 8},
+				},
+				Span: ast.Span{Start: 0, End: 8},
+			},
+			ast.Plus[generator.PrintableInt]{
+				Subexp: ast.Capture[generator.PrintableInt]{
+					Subexp: ast.CharClass[generator.PrintableInt]{
+						Class: ast.NewClass(ast.CharRange{Lo: 'a', Hi: 'b'}),
+						Span:  ast.Span{Start: 10, End: 14},
+					},
+					Index: 1,
+					Span:  ast.Span{Start: 9, End: 15},
+				},
+				Lazy: true,
+				Span: ast.Span{Start: 9, End: 17},
+			},
+		},
+		Span: ast.Span{Start: 0, End: 17},
+	}, regex)
+
+	regex, err = p.Parse(`a|(?i)\Qk\E`)
+	assert.Nil(t, err)
+	or := regex.(ast.Or[generator.PrintableInt])
+	assert.Equal(t, ast.Span{Start: 0, End: 1}, or.Branches[0].Pos())
+	assert.Equal(t, ast.Span{Start: 8, End: 9}, or.Branches[1].Pos())
+
+	regex, err = p.Parse("a|")
+	assert.Nil(t, err)
+	assert.Equal(t, ast.Span{Start: 2, End: 2}, regex.(ast.Or[generator.PrintableInt]).Branches[1].Pos())
+}
+
+// charClass builds the node of a set of the characters in the ranges
+func charClass(ranges ...ast.CharRange) ast.Regex[generator.PrintableInt] {
+	return ast.CharClass[generator.PrintableInt]{Class: ast.NewClass(ranges...)}
+}
+
+// negatedClass builds the node of a set of the characters outside of the ranges
+func negatedClass(ranges ...ast.CharRange) ast.Regex[generator.PrintableInt] {
+	return ast.CharClass[generator.PrintableInt]{Class: ast.NewClass(ranges...).Negate()}
+}
@@ -1,19 +1,403 @@
 package regex
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bogdan-deac/regex/ast"
 	"github.com/bogdan-deac/regex/parser"
 
 	"github.com/bogdan-deac/regex/automata"
 	"github.com/bogdan-deac/regex/common/generator"
 )
 
-func Compile(reS string) (*automata.DFA[generator.PrintableInt], error) {
-	g := generator.NewIntGenerator()
-	p := parser.NewParser()
-	re, err := p.Parse(reS)
+// Regexp is a compiled pattern. It is immutable, so it is safe for concurrent use by multiple
+// goroutines
+type Regexp struct {
+	expr     string
+	searcher *automata.Searcher[generator.PrintableInt]
+}
+
+// Options holds the flags a pattern is compiled with. Each of them can also be set or cleared
+// inline, for the rest of the enclosing group as in (?i)abc or for a single group as in (?i:abc)
+type Options struct {
+	// CaseInsensitive makes letters match regardless of their case, like the i flag
+	CaseInsensitive bool
+	// DotNL makes the . wildcard match newlines as well, like the s flag
+	DotNL bool
+	// MultiLine makes ^ and $ match at the start and end of every line, like the m flag
+	MultiLine bool
+	// Ungreedy swaps the meaning of the greedy and lazy quantifiers, like the U flag
+	Ungreedy bool
+}
+
+// flags returns the parser flags of the options
+func (o Options) flags() parser.Flags {
+	var flags parser.Flags
+	if o.CaseInsensitive {
+		flags |= parser.CaseInsensitive
+	}
+	if o.DotNL {
+		flags |= parser.DotNL
+	}
+	if o.MultiLine {
+		flags |= parser.MultiLine
+	}
+	if o.Ungreedy {
+		flags |= parser.Ungreedy
+	}
+	return flags
+}
+
+// Compile parses a pattern and compiles it into a Regexp. The flags set in any of the options apply
+// to the whole pattern
+func Compile(expr string, opts ...Options) (*Regexp, error) {
+	var flags parser.Flags
+	for _, o := range opts {
+		flags |= o.flags()
+	}
+	p := parser.NewParserWithFlags(flags)
+	re, err := p.Parse(expr)
 	if err != nil {
 		return nil, err
 	}
+	return compile(expr, re), nil
+}
+
+// CompileAST compiles a regex assembled from code, for instance with the build package. The capture
+// groups are numbered in the order of their opening parentheses, whatever their Index. The regex
+// must follow the rules of the parser: valid characters, character ranges in order, repeat counts
+// in order and within parser.MaxRepeat, and valid, unique group names. The errors are
+// *parser.Error values without a pattern. The String method of the resulting Regexp returns the
+// printed regex
+func CompileAST(re ast.Regex[generator.PrintableInt]) (*Regexp, error) {
+	re = ast.NumberCaptures(re)
+	if err := validate(re); err != nil {
+		return nil, err
+	}
+	return compile(ast.Print(re), re), nil
+}
+
+// validate checks a regex assembled from code against the rules that the parser enforces on
+// patterns, so that the regex prints to a pattern which parses back to it
+func validate(re ast.Regex[generator.PrintableInt]) error {
+	var err error
+	seen := make(map[string]bool)
+	ast.Walk(re, func(re ast.Regex[generator.PrintableInt]) {
+		if err != nil {
+			return
+		}
+		switch r := re.(type) {
+		case ast.Char[generator.PrintableInt]:
+			if !utf8.ValidRune(r.Value) {
+				err = astError(parser.InvalidEscape, `invalid character \x{%x}`, r.Value)
+			}
+		case ast.CharClass[generator.PrintableInt]:
+			err = validateClass(r)
+		case ast.Repeat[generator.PrintableInt]:
+			switch {
+			case r.Min < 0 || r.Max != ast.Unbounded && r.Max < r.Min:
+				counts := fmt.Sprintf("{%d,%d}", r.Min, r.Max)
+				if r.Max == ast.Unbounded {
+					counts = fmt.Sprintf("{%d,}", r.Min)
+				}
+				err = astError(parser.InvalidRepeat, "invalid repeat count %s", counts)
+			case r.Min > parser.MaxRepeat || r.Max > parser.MaxRepeat:
+				err = astError(parser.InvalidRepeat, "repeat count exceeds the maximum of %d", parser.MaxRepeat)
+			}
+		case ast.Capture[generator.PrintableInt]:
+			switch {
+			case r.Name == "":
+			case !parser.IsGroupName(r.Name):
+				err = astError(parser.InvalidGroup, "invalid group name %q", r.Name)
+			case seen[r.Name]:
+				err = astError(parser.DuplicateGroupName, "duplicate group name %q", r.Name)
+			}
+			seen[r.Name] = true
+		}
+	})
+	if err == nil && ast.RepeatSize(re, parser.MaxRepeat) > parser.MaxRepeat {
+		err = astError(parser.InvalidRepeat, "nested repeat counts exceed the maximum of %d", parser.MaxRepeat)
+	}
+	return err
+}
+
+// validateClass checks that the ranges of a class are in order and that the characters at their
+// ends can be written in a pattern
+func validateClass(class ast.CharClass[generator.PrintableInt]) error {
+	for _, r := range class.Ranges {
+		switch {
+		case r.Lo > r.Hi:
+			return astError(parser.BadRange, `invalid character range \x{%x}-\x{%x}`, r.Lo, r.Hi)
+		case r.Lo < 0 || r.Hi > unicode.MaxRune:
+			return astError(parser.InvalidEscape, `invalid character range \x{%x}-\x{%x}`, r.Lo, r.Hi)
+		}
+	}
+	ranges := class.Ranges
+	if class.Contains(unicode.MaxRune) {
+		// a class which holds the last character is printed as the negation of the other characters
+		ranges = class.Negate().Ranges
+	}
+	for _, r := range ranges {
+		for _, c := range []rune{r.Lo, r.Hi} {
+			if !utf8.ValidRune(c) {
+				return astError(parser.InvalidEscape, `invalid character \x{%x}`, c)
+			}
+		}
+	}
+	return nil
+}
+
+// astError returns an error in a regex assembled from code, which has neither a pattern nor a
+// position
+func astError(kind parser.ErrorKind, format string, args ...any) error {
+	return &parser.Error{Kind: kind, Message: "regex: " + fmt.Sprintf(format, args...)}
+}
+
+func compile(expr string, re ast.Regex[generator.PrintableInt]) *Regexp {
+	g := generator.NewIntGenerator()
+	groupNames := ast.CaptureNames(re)
 	re = re.Optimize()
-	return re.Compile(g).ToDFA(g).Minimize(), nil
+	return &Regexp{
+		expr:     expr,
+		searcher: automata.NewSearcher(re.Compile(g), groupNames, g),
+	}
+}
+
+// MustCompile is like Compile, but panics if the pattern cannot be parsed. It simplifies the
+// initialization of global variables holding compiled patterns
+func MustCompile(expr string, opts ...Options) *Regexp {
+	re, err := Compile(expr, opts...)
+	if err != nil {
+		panic("regex: Compile(" + strconv.Quote(expr) + "): " + err.Error())
+	}
+	return re
+}
+
+// QuoteMeta escapes all the special characters of a text, so that the resulting pattern matches
+// the text literally
+func QuoteMeta(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`\.+*?()|[]{}^$`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// String returns the source pattern
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// DFA returns a minimal DFA which accepts the inputs that the pattern matches entirely, as if it was
+// anchored at both ends. Each call builds a new DFA
+func (re *Regexp) DFA() *automata.DFA[generator.PrintableInt] {
+	g := generator.NewIntGenerator()
+	return re.searcher.NFA.ToDFA(g).Minimize()
+}
+
+// Intersect returns a minimal DFA which accepts the inputs that both patterns match entirely. It
+// is empty when no input can match both patterns
+func Intersect(a, b *Regexp) *automata.DFA[generator.PrintableInt] {
+	return a.DFA().Intersect(b.DFA(), generator.NewIntGenerator())
+}
+
+// Union returns a minimal DFA which accepts the inputs that either pattern matches entirely
+func Union(a, b *Regexp) *automata.DFA[generator.PrintableInt] {
+	return a.DFA().Union(b.DFA(), generator.NewIntGenerator())
+}
+
+// Difference returns a minimal DFA which accepts the inputs that a matches entirely but b does not
+func Difference(a, b *Regexp) *automata.DFA[generator.PrintableInt] {
+	return a.DFA().Difference(b.DFA(), generator.NewIntGenerator())
+}
+
+// SymmetricDifference returns a minimal DFA which accepts the inputs that exactly one of the
+// patterns matches entirely
+func SymmetricDifference(a, b *Regexp) *automata.DFA[generator.PrintableInt] {
+	return a.DFA().SymmetricDifference(b.DFA(), generator.NewIntGenerator())
+}
+
+// Equivalent reports whether both patterns match entirely the same inputs. If they do not, it also
+// returns one of the shortest inputs matched entirely by only one of them
+func Equivalent(a, b *Regexp) (string, bool) {
+	witness, ok := automata.Equivalent(a.DFA(), b.DFA())
+	return string(witness), ok
+}
+
+// Subset reports whether b matches entirely all the inputs that a matches entirely. If it does not,
+// it also returns one of the shortest inputs matched entirely by a but not by b
+func Subset(a, b *Regexp) (string, bool) {
+	witness, ok := automata.Subset(a.DFA(), b.DFA())
+	return string(witness), ok
+}
+
+// NumSubexp returns the number of capture groups
+func (re *Regexp) NumSubexp() int {
+	return len(re.searcher.GroupNames) - 1
+}
+
+// SubexpNames returns the names of the capture groups, indexed by group number. Group 0 is the
+// whole match and unnamed groups have empty names
+func (re *Regexp) SubexpNames() []string {
+	return append([]string(nil), re.searcher.GroupNames...)
+}
+
+// SubexpIndex returns the number of the capture group with the given name, or -1 if there is none
+func (re *Regexp) SubexpIndex(name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, groupName := range re.searcher.GroupNames {
+		if groupName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// MatchString reports whether the string contains any match of the pattern
+func (re *Regexp) MatchString(s string) bool {
+	return re.FindStringIndex(s) != nil
+}
+
+// Match reports whether the byte slice contains any match of the pattern
+func (re *Regexp) Match(b []byte) bool {
+	return re.FindIndex(b) != nil
+}
+
+// FindStringIndex returns the byte offsets of the leftmost-longest match in the string, or nil if
+// there is none
+func (re *Regexp) FindStringIndex(s string) []int {
+	matches := re.FindAllStringIndex(s, 1)
+	if matches == nil {
+		return nil
+	}
+	return matches[0]
+}
+
+// FindString returns the text of the leftmost-longest match in the string. If there is no match,
+// the result is empty, which is indistinguishable from an empty match - use FindStringIndex to
+// tell the two apart
+func (re *Regexp) FindString(s string) string {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return ""
+	}
+	return s[loc[0]:loc[1]]
+}
+
+// FindAllStringIndex returns the byte offsets of at most n successive non-overlapping matches in
+// the string. If n is negative, all the matches are returned
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	input, offsets := decode(s)
+	matches := re.searcher.FindAllIndex(input, n)
+	for _, loc := range matches {
+		loc[0], loc[1] = offsets[loc[0]], offsets[loc[1]]
+	}
+	return matches
+}
+
+// FindAllString returns the text of at most n successive non-overlapping matches in the string.
+// If n is negative, all the matches are returned
+func (re *Regexp) FindAllString(s string, n int) []string {
+	var matches []string
+	for _, loc := range re.FindAllStringIndex(s, n) {
+		matches = append(matches, s[loc[0]:loc[1]])
+	}
+	return matches
+}
+
+// FindStringSubmatchIndex returns the byte offsets of the leftmost-longest match in the string,
+// followed by the offsets of each capture group inside it. Groups that did not take part in the
+// match have -1 offsets. It returns nil if there is no match
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	matches := re.FindAllStringSubmatchIndex(s, 1)
+	if matches == nil {
+		return nil
+	}
+	return matches[0]
+}
+
+// FindStringSubmatch returns the text of the leftmost-longest match in the string, followed by the
+// text of each capture group. Groups that did not take part in the match are empty. It returns nil
+// if there is no match
+func (re *Regexp) FindStringSubmatch(s string) []string {
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil
+	}
+	submatches := make([]string, len(loc)/2)
+	for i := range submatches {
+		if loc[2*i] >= 0 {
+			submatches[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return submatches
+}
+
+// FindAllStringSubmatchIndex is the 'All' version of FindStringSubmatchIndex. If n is negative,
+// all the matches are returned
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	input, offsets := decode(s)
+	var matches [][]int
+	for _, match := range re.searcher.FindAllMatches(input, n) {
+		loc := match.Offsets
+		for i, offset := range loc {
+			if offset >= 0 {
+				loc[i] = offsets[offset]
+			}
+		}
+		matches = append(matches, loc)
+	}
+	return matches
+}
+
+// FindIndex returns the byte offsets of the leftmost-longest match in the byte slice, or nil if
+// there is none
+func (re *Regexp) FindIndex(b []byte) []int {
+	return re.FindStringIndex(string(b))
+}
+
+// Find returns the leftmost-longest match in the byte slice, or nil if there is none
+func (re *Regexp) Find(b []byte) []byte {
+	loc := re.FindIndex(b)
+	if loc == nil {
+		return nil
+	}
+	return b[loc[0]:loc[1]:loc[1]]
+}
+
+// FindAllIndex returns the byte offsets of at most n successive non-overlapping matches in the
+// byte slice. If n is negative, all the matches are returned
+func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
+	return re.FindAllStringIndex(string(b), n)
+}
+
+// FindAll returns at most n successive non-overlapping matches in the byte slice. If n is negative,
+// all the matches are returned
+func (re *Regexp) FindAll(b []byte, n int) [][]byte {
+	var matches [][]byte
+	for _, loc := range re.FindAllIndex(b, n) {
+		matches = append(matches, b[loc[0]:loc[1]:loc[1]])
+	}
+	return matches
+}
+
+// decode splits a string into symbols. It also returns the byte offset of each symbol, followed by
+// the length of the string, to map the offsets of matches back to the string
+func decode(s string) ([]automata.Symbol, []int) {
+	input := make([]automata.Symbol, 0, len(s))
+	offsets := make([]int, 0, len(s)+1)
+	for i, c := range s {
+		input = append(input, c)
+		offsets = append(offsets, i)
+	}
+	return input, append(offsets, len(s))
 }
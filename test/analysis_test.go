@@ -0,0 +1,119 @@
+package regex_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/bogdan-deac/regex"
+	"github.com/bogdan-deac/regex/automata"
+	"github.com/bogdan-deac/regex/common/generator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalysis(t *testing.T) {
+	tt := []struct {
+		regexS string
+		empty  bool
+		// count is empty for infinite languages
+		count string
+	}{
+		{regexS: "a|b", count: "2"},
+		{regexS: "(ab)?c", count: "2"},
+		{regexS: "[a-z]{2}", count: "676"},
+		{regexS: `\d{3}-\d{4}`, count: "10000000"},
+		{regexS: "[a-z]{20}", count: "19928148895209409152340197376"},
+		// the surrogate halves are not counted, as no string can hold them
+		{regexS: ".", count: "1112063"},
+		{regexS: `[\x{D000}-\x{E000}]`, count: "2049"},
+		{regexS: `(?i)k`, count: "3"},
+		{regexS: "a*"},
+		{regexS: "x(ab)+y"},
+		{regexS: `a\bb`, empty: true, count: "0"},
+		{regexS: `$a`, empty: true, count: "0"},
+	}
+	for _, tc := range tt {
+		dfa := regex.MustCompile(tc.regexS).DFA()
+		assert.Equal(t, tc.empty, dfa.IsEmpty(), tc.regexS)
+		assert.Equal(t, tc.count != "", dfa.IsFinite(), tc.regexS)
+		if tc.count == "" {
+			assert.Nil(t, dfa.Count(), tc.regexS)
+		} else {
+			assert.Equal(t, tc.count, dfa.Count().String(), tc.regexS)
+		}
+	}
+
+	// cycles which cannot lead to a final state, like the loop of a dead state, do not make a
+	// language infinite
+	dfa := regex.MustCompile("a{0,2}b").DFA().Complete(automata.ASCII, generator.NewIntGenerator())
+	assert.True(t, dfa.IsFinite())
+	assert.Equal(t, "3", dfa.Count().String())
+	dfa = regex.Intersect(regex.MustCompile("a*b"), regex.MustCompile("a{0,2}b|c+"))
+	assert.Equal(t, "3", dfa.Count().String())
+	assert.True(t, regex.Intersect(regex.MustCompile("[a-z]+"), regex.MustCompile("[0-9]+")).IsEmpty())
+	// a language of surrogate halves holds no string
+	dfa = regex.Difference(regex.MustCompile(`[\x{D7FF}-\x{E000}]`), regex.MustCompile(`[\x{D7FF}\x{E000}]`))
+	assert.True(t, dfa.IsEmpty())
+	assert.Equal(t, "0", dfa.Count().String())
+	for s := range dfa.Strings(-1, -1) {
+		assert.Fail(t, "unexpected string", s)
+	}
+
+	noNewline := automata.Unicode.Difference(automata.SymbolSet('\n'))
+	assert.True(t, regex.MustCompile(`(?s).*`).DFA().IsUniversal(automata.Unicode))
+	assert.False(t, regex.MustCompile(`.*`).DFA().IsUniversal(automata.Unicode))
+	assert.True(t, regex.MustCompile(`.*`).DFA().IsUniversal(noNewline))
+	assert.True(t, regex.MustCompile(`[\x00-\x7F]*`).DFA().IsUniversal(automata.ASCII))
+	assert.False(t, regex.MustCompile(`[\x00-\x7F]+`).DFA().IsUniversal(automata.ASCII))
+	assert.False(t, regex.MustCompile(`[\x00-\x7E]*`).DFA().IsUniversal(automata.ASCII))
+	// the states only reachable on symbols outside of the alphabet do not matter
+	assert.True(t, regex.MustCompile(`[\x00-\x7F]*|é`).DFA().IsUniversal(automata.ASCII))
+	assert.False(t, regex.MustCompile(`[\x00-\x7F]*|é`).DFA().IsUniversal(automata.NewIntervalSet(automata.Interval{Lo: 0, Hi: 'é'})))
+}
+
+func TestStrings(t *testing.T) {
+	tt := []struct {
+		regexS    string
+		maxLength int
+		maxCount  int
+		expected  []string
+	}{
+		{regexS: "b|a|ab|ba|", maxLength: -1, maxCount: -1, expected: []string{"", "a", "b", "ab", "ba"}},
+		{regexS: "[a-c]x?", maxLength: -1, maxCount: -1, expected: []string{"a", "b", "c", "ax", "bx", "cx"}},
+		{regexS: "(ab)*", maxLength: 6, maxCount: -1, expected: []string{"", "ab", "abab", "ababab"}},
+		{regexS: "(ab)*", maxLength: -1, maxCount: 3, expected: []string{"", "ab", "abab"}},
+		{regexS: "a*b", maxLength: 3, maxCount: 2, expected: []string{"b", "ab"}},
+		{regexS: "[ab]*", maxLength: 2, maxCount: -1, expected: []string{"", "a", "b", "aa", "ab", "ba", "bb"}},
+		{regexS: "(?i)k", maxLength: -1, maxCount: -1, expected: []string{"K", "k", "K"}},
+		{regexS: `a\bb`, maxLength: -1, maxCount: -1},
+		{regexS: "x", maxLength: -1, maxCount: 0},
+		// the first inputs of a sparse language are found without enumerating the rejected ones
+		{regexS: ".*z{5}", maxLength: -1, maxCount: 2, expected: []string{"zzzzz", "\x00zzzzz"}},
+	}
+	for _, tc := range tt {
+		dfa := regex.MustCompile(tc.regexS).DFA()
+		var actual []string
+		for s := range dfa.Strings(tc.maxLength, tc.maxCount) {
+			actual = append(actual, s)
+		}
+		assert.Equal(t, tc.expected, actual, tc.regexS)
+	}
+
+	// the enumeration stops when the loop breaks
+	var first string
+	for s := range regex.MustCompile("[0-9]+").DFA().Strings(-1, -1) {
+		first = s
+		break
+	}
+	assert.Equal(t, "0", first)
+
+	// the strings of a finite language are all enumerated, as many as it counts
+	for _, pattern := range []string{`[a-c]{1,3}|x(y|z)?`, ".", `[\x{D7FF}-\x{E000}]a?`} {
+		dfa := regex.MustCompile(pattern).DFA()
+		n := 0
+		for s := range dfa.Strings(-1, -1) {
+			assert.True(t, dfa.Accepts([]automata.Symbol(s)), s)
+			n++
+		}
+		assert.Equal(t, dfa.Count().String(), big.NewInt(int64(n)).String(), pattern)
+	}
+}
@@ -0,0 +1,115 @@
+package regex_test
+
+import (
+	"testing"
+
+	"github.com/bogdan-deac/regex"
+	"github.com/bogdan-deac/regex/ast"
+	"github.com/bogdan-deac/regex/build"
+	"github.com/bogdan-deac/regex/common/generator"
+	"github.com/bogdan-deac/regex/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	tt := []struct {
+		built           build.Regex
+		expectedPattern string
+		input           string
+		expectedMatches []string
+	}{
+		{
+			built:           build.Seq(build.Lit("http"), build.Opt(build.Lit("s")), build.Lit("://"), build.Plus(build.Class('a', 'z'))),
+			expectedPattern: "https?://[a-z]+",
+			input:           "see http://go and https://example",
+			expectedMatches: []string{"http://go", "https://example"},
+		},
+		{
+			built:           build.Seq(build.Lit("a.b*"), build.Lazy(build.Repeat(build.AnyOf("(|)"), 1, build.Unbounded))),
+			expectedPattern: `a\.b\*[()|]{1,}?`,
+			input:           "ab a.b* a.bb* a.b*(|)",
+			expectedMatches: []string{"a.b*(|)"},
+		},
+		{
+			built:           build.Alt(build.Seq(build.Begin(), build.Lit("x")), build.Seq(build.WordBoundary(), build.NoneOf("x\n"), build.Star(build.Any()), build.End())),
+			expectedPattern: `^x|\b[^\nx](?s:.)*$`,
+			input:           "x y\nz",
+			expectedMatches: []string{"x", " y\nz"},
+		},
+		{
+			built:           build.Seq(build.Lit("a"), build.Alt(), build.Opt(build.Empty())),
+			expectedPattern: `a[^\d\D](?:)?`,
+			input:           "aaa",
+		},
+	}
+	for _, tc := range tt {
+		re, err := regex.CompileAST(tc.built)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expectedPattern, re.String())
+		assert.Equal(t, tc.expectedMatches, re.FindAllString(tc.input, -1), tc.expectedPattern)
+
+		// the builder assembles the same regex as the parser
+		parsed, err := parser.NewParser().Parse(tc.expectedPattern)
+		assert.Nil(t, err)
+		assert.Equal(t, ast.StripSpans(parsed), tc.built)
+	}
+}
+
+func TestBuildGroups(t *testing.T) {
+	// groups are numbered in the order of their opening parentheses
+	key := build.Named("key", build.Plus(build.Group(build.Class('a', 'z'))))
+	re, err := regex.CompileAST(build.Seq(build.Group(key), build.Lit("="), build.Named("value", build.Plus(build.Class('0', '9')))))
+	assert.Nil(t, err)
+	assert.Equal(t, "((?P<key>([a-z])+))=(?P<value>[0-9]+)", re.String())
+	assert.Equal(t, []string{"", "", "key", "", "value"}, re.SubexpNames())
+	assert.Equal(t, []string{"ab=12", "ab", "ab", "b", "12"}, re.FindStringSubmatch("x ab=12"))
+
+	_, err = regex.CompileAST(build.Alt(build.Named("x", build.Lit("a")), build.Named("x", build.Lit("b"))))
+	assert.EqualError(t, err, `regex: duplicate group name "x"`)
+}
+
+func TestBuildInvalid(t *testing.T) {
+	x := build.Lit("x")
+	tt := []struct {
+		built         build.Regex
+		expectedKind  parser.ErrorKind
+		expectedError string
+	}{
+		{built: build.Repeat(x, 5, 2), expectedKind: parser.InvalidRepeat, expectedError: "regex: invalid repeat count {5,2}"},
+		{built: build.Repeat(x, -1, 2), expectedKind: parser.InvalidRepeat, expectedError: "regex: invalid repeat count {-1,2}"},
+		{built: build.Repeat(x, -2, build.Unbounded), expectedKind: parser.InvalidRepeat, expectedError: "regex: invalid repeat count {-2,}"},
+		{built: build.Repeat(x, 2, -2), expectedKind: parser.InvalidRepeat, expectedError: "regex: invalid repeat count {2,-2}"},
+		{built: build.Repeat(x, 1001, build.Unbounded), expectedKind: parser.InvalidRepeat, expectedError: "regex: repeat count exceeds the maximum of 1000"},
+		{built: build.Repeat(x, 0, 1001), expectedKind: parser.InvalidRepeat, expectedError: "regex: repeat count exceeds the maximum of 1000"},
+		{built: build.Repeat(build.Repeat(x, 10, 10), 101, 101), expectedKind: parser.InvalidRepeat, expectedError: "regex: nested repeat counts exceed the maximum of 1000"},
+		{built: build.Named("a>b", x), expectedKind: parser.InvalidGroup, expectedError: `regex: invalid group name "a>b"`},
+		{built: build.Named("a-b", x), expectedKind: parser.InvalidGroup, expectedError: `regex: invalid group name "a-b"`},
+		{built: build.Class(0xD800, 0xDFFF), expectedKind: parser.InvalidEscape, expectedError: `regex: invalid character \x{d800}`},
+		{built: build.Class('a', 0xD800), expectedKind: parser.InvalidEscape, expectedError: `regex: invalid character \x{d800}`},
+		{built: build.Class(-3, 'a'), expectedKind: parser.InvalidEscape, expectedError: `regex: invalid character range \x{-3}-\x{61}`},
+		{built: build.Seq(x, ast.Char[generator.PrintableInt]{Value: 0x110000}), expectedKind: parser.InvalidEscape, expectedError: `regex: invalid character \x{110000}`},
+		{
+			built:         ast.CharClass[generator.PrintableInt]{Class: ast.Class{Ranges: []ast.CharRange{{Lo: 'b', Hi: 'a'}}}},
+			expectedKind:  parser.BadRange,
+			expectedError: `regex: invalid character range \x{62}-\x{61}`,
+		},
+	}
+	for _, tc := range tt {
+		_, err := regex.CompileAST(tc.built)
+		var parseErr *parser.Error
+		if assert.ErrorAs(t, err, &parseErr) {
+			assert.Equal(t, tc.expectedKind, parseErr.Kind)
+			assert.EqualError(t, err, tc.expectedError)
+		}
+	}
+
+	// the surrogate halves can be left out of classes which are printed negated
+	re, err := regex.CompileAST(build.NoneOf("\ue000"))
+	assert.Nil(t, err)
+	assert.Equal(t, `[^\x{e000}]`, re.String())
+
+	// the counts up to the maximum are valid
+	re, err = regex.CompileAST(build.Repeat(build.Repeat(x, 10, 10), 0, 100))
+	assert.Nil(t, err)
+	assert.Equal(t, "(?:x{10}){0,100}", re.String())
+}
@@ -0,0 +1,123 @@
+package regex_test
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/bogdan-deac/regex"
+	"github.com/bogdan-deac/regex/ast"
+	"github.com/bogdan-deac/regex/automata"
+	"github.com/bogdan-deac/regex/common/generator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClass(t *testing.T) {
+	r := func(lo, hi rune) ast.CharRange { return ast.CharRange{Lo: lo, Hi: hi} }
+
+	// ranges are sorted, and merged when they overlap or touch
+	class := ast.NewClass(r('x', 'z'), r('a', 'c'), r('d', 'f'), r('b', 'b'), r('q', 'p'))
+	assert.Equal(t, []ast.CharRange{r('a', 'f'), r('x', 'z')}, class.Ranges)
+	assert.Equal(t, 9, class.Len())
+	assert.True(t, class.Contains('e'))
+	assert.False(t, class.Contains('g'))
+	assert.False(t, class.Contains(0))
+
+	assert.Equal(t,
+		[]ast.CharRange{r('a', 'f'), r('m', 'n'), r('x', 'z')},
+		class.Union(ast.NewClass(r('m', 'n'), r('y', 'z'))).Ranges,
+	)
+	assert.Equal(t,
+		[]ast.CharRange{r('c', 'f'), r('x', 'y')},
+		class.Intersect(ast.NewClass(r('c', 'k'), r('w', 'y'))).Ranges,
+	)
+	assert.True(t, class.Intersect(ast.NewClass(r('g', 'w'))).IsEmpty())
+
+	negated := class.Negate()
+	assert.Equal(t, []ast.CharRange{r(0, '`'), r('g', 'w'), r('{', unicode.MaxRune)}, negated.Ranges)
+	assert.Equal(t, class, negated.Negate())
+	assert.True(t, class.Intersect(negated).IsEmpty())
+	assert.Equal(t, int(unicode.MaxRune)+1, class.Union(negated).Len())
+
+	assert.Equal(t, []ast.CharRange{r('K', 'K'), r('k', 'k'), r('K', 'K')}, ast.NewClass(r('k', 'k')).Fold().Ranges)
+}
+
+func TestCharClassTransitions(t *testing.T) {
+	// a class compiles to a single transition labelled with its ranges, however many characters it
+	// holds
+	class := ast.NewClass(ast.CharRange{Lo: 'a', Hi: 'z'}, ast.CharRange{Lo: 0x100, Hi: 0xFFFF})
+	nfa := ast.CharClass[generator.PrintableInt]{Class: class}.Compile(generator.NewIntGenerator())
+	if assert.Len(t, nfa.Delta[nfa.IntialState], 1) {
+		assert.Equal(t, automata.IntervalSet(class.Ranges), nfa.Delta[nfa.IntialState][0].Label)
+	}
+
+	tt := []struct {
+		regexS   string
+		input    string
+		expected bool
+	}{
+		{regexS: `^[a-z\x{100}-\x{FFF}]+$`, input: "aĀ\u0fffz", expected: true},
+		{regexS: `^[a-z\x{100}-\x{FFF}]+$`, input: "aÿ", expected: false},
+		{regexS: `^[^\x{100}-\x{10FFFF}]+$`, input: "abÿ", expected: true},
+		{regexS: `^[^\x{100}-\x{10FFFF}]+$`, input: "aĀ", expected: false},
+		{regexS: `(?i)[^k]`, input: "K", expected: false},
+		{regexS: `(?i)\W`, input: "K", expected: false},
+	}
+	for _, tc := range tt {
+		re := regex.MustCompile(tc.regexS)
+		assert.Equalf(t, tc.expected, re.MatchString(tc.input), "%s on %q", tc.regexS, tc.input)
+	}
+}
+
+func TestSymbolicTransitions(t *testing.T) {
+	// wildcards and large classes are a single transition, whatever the number of symbols they hold
+	for _, pattern := range []string{"(?s:.)", ".", "[^a-c]", `[\x{100}-\x{10FFFF}]`, `\W`} {
+		dfa := compileDFA(t, pattern)
+		assert.Lenf(t, dfa.Delta[dfa.InitialState], 1, pattern)
+		assert.Equal(t, 2, dfa.AllStates.Cardinality(), pattern)
+	}
+
+	// the symbols are split into the minterms that the transitions tell apart
+	dfa := compileDFA(t, "[a-m]1|[h-z]2")
+	assert.Equal(t, []automata.IntervalSet{
+		automata.SymbolSet('1'),
+		automata.SymbolSet('2'),
+		automata.NewIntervalSet(automata.Interval{Lo: 'a', Hi: 'g'}),
+		automata.NewIntervalSet(automata.Interval{Lo: 'h', Hi: 'm'}),
+		automata.NewIntervalSet(automata.Interval{Lo: 'n', Hi: 'z'}),
+	}, dfa.Minterms())
+	for _, s := range []string{"a1", "k1", "k2", "z2"} {
+		assert.True(t, dfa.Accepts([]automata.Symbol(s)), s)
+	}
+	for _, s := range []string{"a2", "z1", "é1"} {
+		assert.False(t, dfa.Accepts([]automata.Symbol(s)), s)
+	}
+}
+
+func TestTable(t *testing.T) {
+	dfa := compileDFA(t, "[a-c]x|y")
+	table := dfa.Table()
+
+	// symbols which no transition tells apart share a class, and class 0 holds the symbols without
+	// any transition
+	assert.Equal(t, table.Class('a'), table.Class('b'))
+	assert.NotEqual(t, table.Class('a'), table.Class('x'))
+	assert.Equal(t, uint32(0), table.Class('é'))
+	assert.Equal(t, uint32(0), table.Class(0x10FFFF))
+	assert.Len(t, table.Transitions, (dfa.AllStates.Cardinality()+1)*table.NumClasses)
+	assert.Same(t, table, dfa.Table())
+
+	for _, s := range []string{"ax", "cx", "y", "", "a", "bx!", "éx", "yy"} {
+		input := []automata.Symbol(s)
+		state, accepted := dfa.InitialState, true
+		for _, sym := range input {
+			next, ok := dfa.Step(state, sym)
+			if !ok {
+				accepted = false
+				break
+			}
+			state = next
+		}
+		accepted = accepted && dfa.FinalStates.Contains(state)
+		assert.Equal(t, accepted, table.Accepts(input), s)
+	}
+}
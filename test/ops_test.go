@@ -0,0 +1,172 @@
+package regex_test
+
+import (
+	"testing"
+
+	"github.com/bogdan-deac/regex"
+	"github.com/bogdan-deac/regex/automata"
+	"github.com/bogdan-deac/regex/common/generator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplement(t *testing.T) {
+	g := generator.NewIntGenerator()
+
+	tt := []struct {
+		regexS   string
+		alphabet automata.IntervalSet
+		states   int
+		accepted []string
+		rejected []string
+	}{
+		{
+			regexS:   "a+",
+			alphabet: automata.ASCII,
+			states:   3,
+			accepted: []string{"", "b", "ab", "ba", "aab"},
+			rejected: []string{"a", "aaa", "é", "aé"},
+		},
+		{
+			regexS:   "[^a]",
+			alphabet: automata.Bytes,
+			states:   3,
+			accepted: []string{"", "a", "bb", "ÿÿ"},
+			rejected: []string{"b", "ÿ", "Ā"},
+		},
+		{
+			regexS:   ".*",
+			alphabet: automata.Unicode,
+			states:   2,
+			accepted: []string{"\n", "a\nb"},
+			rejected: []string{"", "ab", "\U0010FFFF"},
+		},
+		{
+			regexS:   "[a-c]x|y",
+			alphabet: automata.NewIntervalSet(automata.Interval{Lo: 'a', Hi: 'b'}, automata.Interval{Lo: 'x', Hi: 'x'}),
+			states:   4,
+			accepted: []string{"", "x", "b", "axx", "bb"},
+			rejected: []string{"ax", "bx", "cx", "y", "c"},
+		},
+	}
+	for _, tc := range tt {
+		complete := compileDFA(t, tc.regexS).Complete(tc.alphabet, g)
+		// every state has a transition on every symbol of the alphabet
+		for state := range complete.AllStates.Iter() {
+			var labels automata.IntervalSet
+			for _, transition := range complete.Delta[state] {
+				labels = labels.Union(transition.Label)
+			}
+			assert.Equal(t, tc.alphabet, labels, tc.regexS)
+		}
+
+		complement := complete.Complement().Minimize()
+		assert.Equal(t, tc.states, complement.AllStates.Cardinality(), tc.regexS)
+		for _, s := range tc.accepted {
+			assert.Truef(t, complement.Accepts([]automata.Symbol(s)), "%s on %q", tc.regexS, s)
+		}
+		for _, s := range tc.rejected {
+			assert.Falsef(t, complement.Accepts([]automata.Symbol(s)), "%s on %q", tc.regexS, s)
+		}
+		// the complement of the complement is the original language
+		original := complement.Complete(tc.alphabet, g).Complement()
+		for _, s := range append(tc.accepted, tc.rejected...) {
+			input := []automata.Symbol(s)
+			assert.Equalf(t, complete.Accepts(input), original.Accepts(input), "%s on %q", tc.regexS, s)
+		}
+	}
+}
+
+func TestProduct(t *testing.T) {
+	tt := []struct {
+		a, b     string
+		op       func(a, b *regex.Regexp) *automata.DFA[generator.PrintableInt]
+		accepted []string
+		rejected []string
+	}{
+		{
+			a: "[a-z]+[0-9]", b: "ab.*", op: regex.Intersect,
+			accepted: []string{"ab1", "abc9"},
+			rejected: []string{"", "ab", "ac1", "b1", "ab12"},
+		},
+		{
+			a: "[a-z]+", b: "[0-9]+", op: regex.Intersect,
+			rejected: []string{"", "a", "1", "a1"},
+		},
+		{
+			a: "a+", b: "b+", op: regex.Union,
+			accepted: []string{"a", "aa", "b", "bbb"},
+			rejected: []string{"", "ab", "c"},
+		},
+		{
+			a: "[a-z]+", b: "if|for", op: regex.Difference,
+			accepted: []string{"i", "fo", "iff", "forr", "x"},
+			rejected: []string{"", "if", "for", "1"},
+		},
+		{
+			a: `\w+`, b: "[^é]+", op: regex.SymmetricDifference,
+			accepted: []string{"-", "a-", "ÿ"},
+			rejected: []string{"", "a_1", "x", "é", "aéb"},
+		},
+		{
+			a: `(?i)k`, b: `\x{212A}`, op: regex.Difference,
+			accepted: []string{"k", "K"},
+			rejected: []string{"K", ""},
+		},
+	}
+	for _, tc := range tt {
+		dfa := tc.op(regex.MustCompile(tc.a), regex.MustCompile(tc.b))
+		for _, s := range tc.accepted {
+			assert.Truef(t, dfa.Accepts([]automata.Symbol(s)), "%s, %s on %q", tc.a, tc.b, s)
+		}
+		for _, s := range tc.rejected {
+			assert.Falsef(t, dfa.Accepts([]automata.Symbol(s)), "%s, %s on %q", tc.a, tc.b, s)
+		}
+	}
+
+	// the results are minimal
+	dfa := regex.Intersect(regex.MustCompile("[a-z]+"), regex.MustCompile("[a-m]+|[n-z]+"))
+	assert.Equal(t, 3, dfa.AllStates.Cardinality())
+	dfa = regex.Union(regex.MustCompile("a(bc)*"), regex.MustCompile("(ab)*"))
+	assert.Equal(t, dfa.AllStates.Cardinality(), regex.MustCompile("a(bc)*|(ab)*").DFA().AllStates.Cardinality())
+}
+
+func TestEquivalent(t *testing.T) {
+	tt := []struct {
+		a, b       string
+		equivalent bool
+		witness    string
+		subset     bool
+	}{
+		{a: "(a|b)*", b: "(a*b*)*", equivalent: true, subset: true},
+		{a: "a(ba)*", b: "(ab)*a", equivalent: true, subset: true},
+		{a: "[a-z]", b: "[a-m]|[n-z]", equivalent: true, subset: true},
+		{a: "(?i)k", b: "[KkK]", equivalent: true, subset: true},
+		{a: "a+", b: "a*", witness: "", subset: true},
+		{a: "a*", b: "a+", witness: "", subset: false},
+		{a: "ab|abcd", b: "ab(cd)?x?", witness: "abx", subset: true},
+		{a: `\d{3}`, b: "[0-8]+", witness: "0", subset: false},
+		{a: "(?s)a.c", b: "a[^b]c", witness: "abc", subset: false},
+	}
+	for _, tc := range tt {
+		a, b := regex.MustCompile(tc.a), regex.MustCompile(tc.b)
+		witness, ok := regex.Equivalent(a, b)
+		assert.Equalf(t, tc.equivalent, ok, "%s, %s", tc.a, tc.b)
+		if !ok {
+			assert.Equalf(t, tc.witness, witness, "%s, %s", tc.a, tc.b)
+			// the witness is matched entirely by exactly one of the patterns
+			assert.NotEqual(t, a.DFA().Accepts([]automata.Symbol(witness)), b.DFA().Accepts([]automata.Symbol(witness)))
+		}
+
+		witness, ok = regex.Subset(a, b)
+		assert.Equalf(t, tc.subset, ok, "%s, %s", tc.a, tc.b)
+		if !ok {
+			assert.Truef(t, a.DFA().Accepts([]automata.Symbol(witness)), "%s on %q", tc.a, witness)
+			assert.Falsef(t, b.DFA().Accepts([]automata.Symbol(witness)), "%s on %q", tc.b, witness)
+		}
+	}
+
+	// the witnesses are among the shortest inputs that tell the patterns apart
+	witness, ok := regex.Subset(regex.MustCompile("a{3,}|b"), regex.MustCompile("a{4}|[ab]"))
+	assert.False(t, ok)
+	assert.Equal(t, "aaa", witness)
+}
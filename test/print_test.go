@@ -0,0 +1,69 @@
+package regex_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/bogdan-deac/regex/ast"
+	"github.com/bogdan-deac/regex/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrint(t *testing.T) {
+	tt := []struct {
+		regexS   string
+		flags    parser.Flags
+		expected string
+	}{
+		{regexS: "a|b|cd", expected: "a|b|cd"},
+		{regexS: "a|(?:b|cd)", expected: "a|(?:b|cd)"},
+		{regexS: "a(?:bc)(?:d|e)*", expected: "a(?:bc)(?:d|e)*"},
+		{regexS: "(?:a+)?|(?:)|x(?:)", expected: "(?:a+)?||x(?:)"},
+		{regexS: "(a)(?P<name>b{2,}?)c{1,3}d{0}", expected: "(a)(?P<name>b{2,}?)c{1,3}d{0}"},
+		{regexS: `[a-df\-\]]\.\{1\}`, expected: `[\-\]a-df]\.\{1\}`},
+		{regexS: `.(?s:.)[^\n\t]\x00é\x{1F600}`, expected: `.(?s:.)[^\t\n]\x{0}é😀`},
+		{regexS: `^\A\b\B$\z`, expected: `^^\b\B$$`},
+		{regexS: "^$", flags: parser.MultiLine, expected: "(?m:^)(?m:$)"},
+		{regexS: "k", flags: parser.CaseInsensitive, expected: "[KkK]"},
+		{regexS: `[^\d\D]|[\d\D]`, expected: `[^\d\D]|[\d\D]`},
+		{regexS: `[^\W][\x{100}-\x{10FFFF}]`, expected: `[0-9A-Z_a-z][^\x{0}-ÿ]`},
+	}
+	for _, tc := range tt {
+		regex, err := parser.NewParserWithFlags(tc.flags).Parse(tc.regexS)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, regex.String(), tc.regexS)
+	}
+}
+
+// TestPrintRoundTrip checks that parsing the pattern printed for a parsed regex gives back the
+// same regex, on random patterns
+func TestPrintRoundTrip(t *testing.T) {
+	pieces := []string{
+		"a", "b", "é", `\n`, `\x{1F600}`, `\x00`, ".", "(?s:.)", "[a-c]", "[^a]", "[-]", `[\]]`, `[\d\D]`,
+		`[^\d\D]`, `\d`, `\W`, "(", ")", "(?:", "(?P<x>", "|", "*", "+", "?", "??", "{2}", "{1,3}", "{2,}?",
+		"^", "$", `\b`, `\B`, `\A`, `\z`, "(?m)", "(?i)", "(?U)", `\Q*|\E`, "-", `\\`, `\.`, "{", "}",
+	}
+	r := rand.New(rand.NewSource(1))
+	parsed := 0
+	for range 20000 {
+		var sb strings.Builder
+		for range 1 + r.Intn(10) {
+			sb.WriteString(pieces[r.Intn(len(pieces))])
+		}
+		regex, err := parser.NewParser().Parse(sb.String())
+		if err != nil {
+			continue
+		}
+		parsed++
+
+		printed := regex.String()
+		reparsed, err := parser.NewParser().Parse(printed)
+		if assert.Nilf(t, err, "Expected %q printed from %q to parse", printed, sb.String()) {
+			assert.Equal(t, ast.StripSpans(regex), ast.StripSpans(reparsed), sb.String())
+			assert.Equal(t, printed, reparsed.String())
+		}
+	}
+	// the patterns are random, but enough of them should be valid
+	assert.Greater(t, parsed, 2000)
+}
@@ -1,6 +1,7 @@
 package regex_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/bogdan-deac/regex/automata"
@@ -9,10 +10,19 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// compileDFA parses a pattern and builds its minimal DFA
+func compileDFA(t *testing.T, pattern string) *automata.DFA[generator.PrintableInt] {
+	regex, err := parser.NewParser().Parse(pattern)
+	assert.Nil(t, err, pattern)
+	g := generator.NewIntGenerator()
+	return regex.Optimize().Compile(g).ToDFA(g).Minimize()
+}
+
 func TestRegex(t *testing.T) {
 	tt := []struct {
 		regexS     string
 		mustAccept []string
+		mustReject []string
 	}{
 		{
 			regexS:     "a",
@@ -122,6 +132,175 @@ func TestRegex(t *testing.T) {
 			regexS:     "[0-2][1-3]",
 			mustAccept: []string{"01", "02", "11", "13", "22"},
 		},
+		{
+			regexS:     "[^abc]",
+			mustAccept: []string{"d", "z", "A", "0", " "},
+			mustReject: []string{"a", "b", "c", "", "dd"},
+		},
+		{
+			regexS:     `"[^"\\]*"`,
+			mustAccept: []string{`""`, `"abc"`, `"a b'c"`},
+			mustReject: []string{`"a"b"`, `"a\\b"`, `"abc`},
+		},
+		{
+			regexS:     "[^a-z0-9]+",
+			mustAccept: []string{"A", "_-", "ABC!"},
+			mustReject: []string{"a", "z", "5", "Aa", "-0"},
+		},
+		{
+			regexS:     "a[^a]|ab",
+			mustAccept: []string{"ab", "ac"},
+			mustReject: []string{"aa", "a"},
+		},
+		{
+			regexS:     "[0-9]{3}",
+			mustAccept: []string{"000", "123", "999"},
+			mustReject: []string{"", "12", "1234", "12a"},
+		},
+		{
+			regexS:     "a{2,4}",
+			mustAccept: []string{"aa", "aaa", "aaaa"},
+			mustReject: []string{"a", "aaaaa"},
+		},
+		{
+			regexS:     "(ab){2,}c",
+			mustAccept: []string{"ababc", "abababababc"},
+			mustReject: []string{"abc", "c", "ababab"},
+		},
+		{
+			regexS:     "a{0}b",
+			mustAccept: []string{"b"},
+			mustReject: []string{"ab"},
+		},
+		{
+			regexS:     "x{0,2}y{1}",
+			mustAccept: []string{"y", "xy", "xxy"},
+			mustReject: []string{"xxxy", "x"},
+		},
+		{
+			regexS:     "é+",
+			mustAccept: []string{"é", "ééé"},
+			mustReject: []string{"", "e", "éa"},
+		},
+		{
+			regexS:     "[α-ω]+",
+			mustAccept: []string{"α", "αβγ", "ω"},
+			mustReject: []string{"a", "Α", "αa"},
+		},
+		{
+			regexS:     ".",
+			mustAccept: []string{"a", "é", "日", "\U0001F600"},
+			mustReject: []string{"", "日本"},
+		},
+		{
+			regexS:     "[^é]",
+			mustAccept: []string{"e", "a", "日", "\U0001F600"},
+			mustReject: []string{"é", ""},
+		},
+		{
+			regexS:     "日本語?|.{3}",
+			mustAccept: []string{"日本", "日本語", "abc", "日本人"},
+			mustReject: []string{"日", "日本語人"},
+		},
+		{
+			regexS:     "[^a-z]+",
+			mustAccept: []string{"日本", "ÀÉ", "A"},
+			mustReject: []string{"日a本"},
+		},
+		{
+			regexS:     `\d+`,
+			mustAccept: []string{"0", "123", "9876543210"},
+			mustReject: []string{"", "12a", "١"},
+		},
+		{
+			regexS:     `\w+\s*=\s*\S+`,
+			mustAccept: []string{"a=1", "key_1 = value", "x\t=\t日本"},
+			mustReject: []string{"a = ", "a b = c", "é=1"},
+		},
+		{
+			regexS:     `\D\W`,
+			mustAccept: []string{"a ", "日!", "_-"},
+			mustReject: []string{"1 ", "aa", "a_"},
+		},
+		{
+			regexS:     `[\d\s]+|[^\d\s]`,
+			mustAccept: []string{"1 2\n3", "x", "日"},
+			mustReject: []string{"xy", "1x"},
+		},
+		{
+			regexS:     "[[:alpha:]_][[:alnum:]_]*",
+			mustAccept: []string{"x", "_tmp", "camelCase9"},
+			mustReject: []string{"9lives", "a-b", ""},
+		},
+		{
+			regexS:     "[[:^digit:][:punct:]]+",
+			mustAccept: []string{"abc", "日本", "!?"},
+			mustReject: []string{"a1", "7"},
+		},
+		{
+			regexS:     `[\D5]`,
+			mustAccept: []string{"5", "a", "日"},
+			mustReject: []string{"4", "0"},
+		},
+		{
+			regexS:     "(?:aa?)?",
+			mustAccept: []string{"", "a", "aa"},
+			mustReject: []string{"aaa"},
+		},
+		{
+			regexS:     "^a*$",
+			mustAccept: []string{"", "a", "aaa"},
+			mustReject: []string{"b", "ab"},
+		},
+		{
+			regexS:     `(^|x)a\z`,
+			mustAccept: []string{"a", "xa"},
+			mustReject: []string{"", "x", "xax"},
+		},
+		{
+			regexS:     "a^b|a$b|\\Aa\\Ab",
+			mustReject: []string{"ab", "a", "b"},
+		},
+		{
+			regexS:     `\ba\b.?`,
+			mustAccept: []string{"a", "a ", "a-", "aé"},
+			mustReject: []string{"ab", "a_", "a1"},
+		},
+		{
+			regexS:     `\B|a\Bb`,
+			mustAccept: []string{"", "ab"},
+			mustReject: []string{"a", "a b"},
+		},
+		{
+			regexS:     `\Q(a|b)*\E\t\x{2a}+`,
+			mustAccept: []string{"(a|b)*\t*", "(a|b)*\t***"},
+			mustReject: []string{"a\t*", "(a|b)*\t", "(a|b)*t*"},
+		},
+		{
+			regexS:     `[\x00-\x1f\\]+`,
+			mustAccept: []string{"\x00", "\n\r\\"},
+			mustReject: []string{"", " ", "a"},
+		},
+		{
+			regexS:     "a|",
+			mustAccept: []string{"", "a"},
+			mustReject: []string{"b", "aa"},
+		},
+		{
+			regexS:     "(|x)y()",
+			mustAccept: []string{"y", "xy"},
+			mustReject: []string{"", "x", "xxy"},
+		},
+		{
+			regexS:     `([^\d\D])|a[^\d\D]*b`,
+			mustAccept: []string{"ab"},
+			mustReject: []string{"", "a", "a0b"},
+		},
+		{
+			regexS:     "a{1000}",
+			mustAccept: []string{strings.Repeat("a", 1000)},
+			mustReject: []string{strings.Repeat("a", 999), strings.Repeat("a", 1001)},
+		},
 	}
 	p := parser.NewParser()
 	for _, tc := range tt {
@@ -134,12 +313,58 @@ func TestRegex(t *testing.T) {
 		for _, s := range tc.mustAccept {
 			assert.Truef(t, dfa.Accepts([]automata.Symbol(s)), "Expected %s to match %s", tc.regexS, s)
 		}
+		for _, s := range tc.mustReject {
+			assert.Falsef(t, dfa.Accepts([]automata.Symbol(s)), "Expected %s not to match %s", tc.regexS, s)
+		}
 		// whatever the DFA accepts, the minDFA must also accept
 		minDfa := dfa.Minimize()
 		for _, s := range tc.mustAccept {
 			assert.Truef(t, minDfa.Accepts([]automata.Symbol(s)), "Expected %s to match %s", tc.regexS, s)
 		}
+		for _, s := range tc.mustReject {
+			assert.Falsef(t, minDfa.Accepts([]automata.Symbol(s)), "Expected %s not to match %s", tc.regexS, s)
+		}
 		// automata theory - the min DFA should have at most the same number of states as the DFA
 		assert.True(t, dfa.AllStates.Cardinality() >= minDfa.AllStates.Cardinality())
 	}
 }
+
+func TestMinimize(t *testing.T) {
+	tt := []struct {
+		regexS string
+		states int
+	}{
+		{"(a|b)*abb", 4},
+		{"a(b|c)*|d(c|b)*", 2},
+		{"(ab|cd){3}", 10},
+		{"a{1000}", 1001},
+		{"(x|y)*", 1},
+	}
+	for _, tc := range tt {
+		dfa := compileDFA(t, tc.regexS)
+		assert.Equalf(t, tc.states, dfa.AllStates.Cardinality(), "Expected %s to have %d states", tc.regexS, tc.states)
+	}
+}
+
+func TestStateSpans(t *testing.T) {
+	p := parser.NewParser()
+	regex, err := p.Parse("ab|c{2}")
+	assert.Nil(t, err)
+	g := generator.NewIntGenerator()
+	nfa := regex.Optimize().Compile(g)
+
+	// every state maps back to the pattern, and the symbol transitions to their characters
+	expected := map[automata.Symbol]automata.Span{
+		'a': {Start: 0, End: 1},
+		'b': {Start: 1, End: 2},
+		'c': {Start: 3, End: 4},
+	}
+	for state := range nfa.AllStates.Iter() {
+		span, ok := nfa.Spans[state]
+		assert.Truef(t, ok, "Expected state %d to have a span", state)
+		for _, transition := range nfa.Delta[state] {
+			assert.Equal(t, expected[transition.Label[0].Lo], span)
+		}
+	}
+	assert.Equal(t, automata.Span{Start: 0, End: 7}, nfa.Spans[nfa.IntialState])
+}
@@ -0,0 +1,338 @@
+package regex_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bogdan-deac/regex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	tt := []struct {
+		regexS          string
+		input           string
+		expectedMatches [][]int
+	}{
+		{
+			regexS:          "a+",
+			input:           "baaab",
+			expectedMatches: [][]int{{1, 4}},
+		},
+		{
+			regexS:          "a*",
+			input:           "baaab",
+			expectedMatches: [][]int{{0, 0}, {1, 4}, {5, 5}},
+		},
+		{
+			regexS:          "abcd|c",
+			input:           "xabcd",
+			expectedMatches: [][]int{{1, 5}},
+		},
+		{
+			regexS:          "ab|abcd",
+			input:           "xabcdab",
+			expectedMatches: [][]int{{1, 5}, {5, 7}},
+		},
+		{
+			regexS:          "[0-9]{3}",
+			input:           "a1234b567",
+			expectedMatches: [][]int{{1, 4}, {6, 9}},
+		},
+		{
+			regexS:          `"[^"]*"`,
+			input:           `say "hi" and "bye"`,
+			expectedMatches: [][]int{{4, 8}, {13, 18}},
+		},
+		{
+			regexS:          "x",
+			input:           "aaaa",
+			expectedMatches: nil,
+		},
+		{
+			regexS:          "a.c",
+			input:           "abc→a-c",
+			expectedMatches: [][]int{{0, 3}, {6, 9}},
+		},
+		{
+			regexS:          "caf.",
+			input:           "naïve café",
+			expectedMatches: [][]int{{7, 12}},
+		},
+		{
+			regexS:          "[α-ω]+",
+			input:           "abc αβγ def ω",
+			expectedMatches: [][]int{{4, 10}, {15, 17}},
+		},
+		{
+			regexS:          "^a",
+			input:           "aa\na",
+			expectedMatches: [][]int{{0, 1}},
+		},
+		{
+			regexS:          "a*$",
+			input:           "baa",
+			expectedMatches: [][]int{{1, 3}},
+		},
+		{
+			regexS:          "^|$",
+			input:           "ab",
+			expectedMatches: [][]int{{0, 0}, {2, 2}},
+		},
+		{
+			regexS:          `\Aa|b\z`,
+			input:           "aab",
+			expectedMatches: [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			regexS:          "^$",
+			input:           "",
+			expectedMatches: [][]int{{0, 0}},
+		},
+		{
+			regexS:          `\bfoo\b`,
+			input:           "foo foobar barfoo foo.",
+			expectedMatches: [][]int{{0, 3}, {18, 21}},
+		},
+		{
+			regexS:          `\Bo\B`,
+			input:           "foo",
+			expectedMatches: [][]int{{1, 2}},
+		},
+		{
+			regexS:          `\b`,
+			input:           "ab cd",
+			expectedMatches: [][]int{{0, 0}, {2, 2}, {3, 3}, {5, 5}},
+		},
+	}
+
+	for _, tc := range tt {
+		re := regex.MustCompile(tc.regexS)
+		assert.Equalf(t, tc.expectedMatches, re.FindAllStringIndex(tc.input, -1), "Unexpected matches of %s in %s", tc.regexS, tc.input)
+		assert.Equal(t, tc.expectedMatches, re.FindAllIndex([]byte(tc.input), -1))
+
+		if tc.expectedMatches == nil {
+			assert.False(t, re.MatchString(tc.input))
+			assert.Nil(t, re.FindStringIndex(tc.input))
+			assert.Nil(t, re.Find([]byte(tc.input)))
+			continue
+		}
+		first := tc.expectedMatches[0]
+		assert.True(t, re.MatchString(tc.input))
+		assert.True(t, re.Match([]byte(tc.input)))
+		assert.Equal(t, first, re.FindStringIndex(tc.input))
+		assert.Equal(t, tc.input[first[0]:first[1]], re.FindString(tc.input))
+		assert.Equal(t, []byte(tc.input[first[0]:first[1]]), re.Find([]byte(tc.input)))
+		assert.Len(t, re.FindAllString(tc.input, 1), 1)
+		assert.Len(t, re.FindAll([]byte(tc.input), -1), len(tc.expectedMatches))
+	}
+}
+
+func TestSearchScaling(t *testing.T) {
+	// the reverse DFA of .{30}x has billions of states, which are only built as the input needs them
+	start := time.Now()
+	re := regex.MustCompile(".{30}x")
+	input := strings.Repeat("xyz", 10000)
+	assert.Len(t, re.FindAllStringIndex(input, -1), 909)
+	assert.Equal(t, []int{0, 31}, re.FindStringIndex(input))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// after each match of a, the forward pass stops as soon as the input cannot hold a b anymore
+	start = time.Now()
+	input = strings.Repeat("a", 100000)
+	assert.Len(t, regex.MustCompile("a|a*b").FindAllStringIndex(input, -1), len(input))
+	assert.Equal(t, []int{0, 100001}, regex.MustCompile("a|a*b").FindStringIndex(input+"b"))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestSubmatches(t *testing.T) {
+	tt := []struct {
+		regexS          string
+		input           string
+		expectedOffsets []int
+		expectedNamed   map[string]string
+	}{
+		{
+			regexS:          "(a+)(b+)",
+			input:           "xaabbby",
+			expectedOffsets: []int{1, 6, 1, 3, 3, 6},
+		},
+		{
+			regexS:          "(a*)(a*)",
+			input:           "aa",
+			expectedOffsets: []int{0, 2, 0, 2, 2, 2},
+		},
+		{
+			regexS:          "(a)|(b)",
+			input:           "b",
+			expectedOffsets: []int{0, 1, -1, -1, 0, 1},
+		},
+		{
+			regexS:          "(?:(a)|b)+",
+			input:           "ab",
+			expectedOffsets: []int{0, 2, 0, 1},
+		},
+		{
+			regexS:          "(a|)(|b)c",
+			input:           "bc",
+			expectedOffsets: []int{0, 2, 0, 0, 0, 1},
+		},
+		{
+			regexS:          "(?P<key>[a-z]+)=(?P<value>[0-9]+)",
+			input:           "set x=42;",
+			expectedOffsets: []int{4, 8, 4, 5, 6, 8},
+			expectedNamed:   map[string]string{"key": "x", "value": "42"},
+		},
+		{
+			regexS:          "(?:a(b))c",
+			input:           "abc",
+			expectedOffsets: []int{0, 3, 1, 2},
+		},
+		{
+			regexS:          "(x){0}y",
+			input:           "y",
+			expectedOffsets: []int{0, 1, -1, -1},
+		},
+		{
+			regexS:          "(a+?)(a*)",
+			input:           "aaa",
+			expectedOffsets: []int{0, 3, 0, 1, 1, 3},
+		},
+		{
+			regexS:          "(?U)(a+)(a*?)",
+			input:           "aaa",
+			expectedOffsets: []int{0, 3, 0, 1, 1, 3},
+		},
+		{
+			regexS:          "(a{1,3}?)(a??)(a*)",
+			input:           "aaa",
+			expectedOffsets: []int{0, 3, 0, 1, 1, 1, 1, 3},
+		},
+	}
+
+	for _, tc := range tt {
+		re := regex.MustCompile(tc.regexS)
+		assert.Equalf(t, tc.expectedOffsets, re.FindStringSubmatchIndex(tc.input), "Unexpected submatches of %s in %s", tc.regexS, tc.input)
+		assert.Equal(t, len(tc.expectedOffsets)/2-1, re.NumSubexp())
+		submatches := re.FindStringSubmatch(tc.input)
+		for name, value := range tc.expectedNamed {
+			assert.Equal(t, value, submatches[re.SubexpIndex(name)])
+		}
+	}
+
+	re := regex.MustCompile("(?P<digit>[0-9])")
+	assert.Equal(t, []string{"", "digit"}, re.SubexpNames())
+	assert.Equal(t, -1, re.SubexpIndex("letter"))
+	assert.Equal(t, [][]int{{1, 2, 1, 2}, {3, 4, 3, 4}}, re.FindAllStringSubmatchIndex("a1b2", -1))
+}
+
+func TestOptions(t *testing.T) {
+	tt := []struct {
+		regexS          string
+		opts            regex.Options
+		input           string
+		expectedMatches [][]int
+	}{
+		{
+			regexS:          "^a",
+			opts:            regex.Options{MultiLine: true},
+			input:           "a\na\nba",
+			expectedMatches: [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			regexS:          "a$",
+			opts:            regex.Options{MultiLine: true},
+			input:           "a\nba\nab",
+			expectedMatches: [][]int{{0, 1}, {3, 4}},
+		},
+		{
+			regexS:          "^$",
+			opts:            regex.Options{MultiLine: true},
+			input:           "a\n\nb",
+			expectedMatches: [][]int{{2, 2}},
+		},
+		{
+			regexS:          `\A.|.\z`,
+			opts:            regex.Options{MultiLine: true},
+			input:           "ab\ncd",
+			expectedMatches: [][]int{{0, 1}, {4, 5}},
+		},
+		{
+			regexS:          "(?m)^b",
+			input:           "a\nb",
+			expectedMatches: [][]int{{2, 3}},
+		},
+		{
+			regexS:          "abc",
+			opts:            regex.Options{CaseInsensitive: true},
+			input:           "xABcabc",
+			expectedMatches: [][]int{{1, 4}, {4, 7}},
+		},
+		{
+			regexS:          "(?i:ab)c",
+			input:           "ABc ABC",
+			expectedMatches: [][]int{{0, 3}},
+		},
+		{
+			regexS:          "(?i)a(?-i)a|b",
+			input:           "AA Aa B b",
+			expectedMatches: [][]int{{3, 5}, {8, 9}},
+		},
+		{
+			regexS:          "(?i)[k-m]+",
+			input:           "JKLM\u212a",
+			expectedMatches: [][]int{{1, 7}},
+		},
+		{
+			regexS:          "a.b",
+			input:           "a\nb a-b",
+			expectedMatches: [][]int{{4, 7}},
+		},
+		{
+			regexS:          "a.b",
+			opts:            regex.Options{DotNL: true},
+			input:           "a\nb a-b",
+			expectedMatches: [][]int{{0, 3}, {4, 7}},
+		},
+		{
+			regexS:          "(?s:.)(?-s:.)",
+			input:           "\n\n\nx",
+			expectedMatches: [][]int{{2, 4}},
+		},
+	}
+
+	for _, tc := range tt {
+		re := regex.MustCompile(tc.regexS, tc.opts)
+		assert.Equalf(t, tc.expectedMatches, re.FindAllStringIndex(tc.input, -1), "Unexpected matches of %s in %q", tc.regexS, tc.input)
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	re, err := regex.Compile("a(b|c)*")
+	assert.Nil(t, err)
+	assert.Equal(t, "a(b|c)*", re.String())
+
+	_, err = regex.Compile("a(b")
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { regex.MustCompile("a(b") })
+
+	quoted := regex.QuoteMeta(`1+1=2? [x](y){z}|a*b.c\d`)
+	assert.Equal(t, `1\+1=2\? \[x\]\(y\)\{z\}\|a\*b\.c\\d`, quoted)
+	assert.Equal(t, `1+1=2? [x](y){z}|a*b.c\d`, regex.MustCompile(quoted).FindString(`so 1+1=2? [x](y){z}|a*b.c\d`))
+
+	// a single compiled pattern is shared by many goroutines
+	shared := regex.MustCompile("[0-9]+")
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				assert.Equal(t, []string{"12", "345"}, shared.FindAllString("a12b345", -1))
+			}
+		}()
+	}
+	wg.Wait()
+}